@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/PaluMacil/gophernet/m"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// autoencoderFlags are the flags shared by the encode and reconstruct commands
+type autoencoderFlags struct {
+	flags   *flag.FlagSet
+	data    *string
+	outputs *int
+	out     *string
+	layout  dataFlags
+}
+
+func addAutoencoderFlags(flags *flag.FlagSet) autoencoderFlags {
+	return autoencoderFlags{
+		flags:   flags,
+		data:    flags.String("data", "", "data is the file of inputs to read (default is <dataset>.data)"),
+		outputs: flags.Int("outputs", 0, "outputs is the number of target columns after the inputs, which are copied to the output (default is the number the autoencoder was trained with)"),
+		out:     flags.String("out", "-", "out is the file to write (default is stdout)"),
+		layout:  addDataFlags(flags),
+	}
+}
+
+// open loads the autoencoder, which can only be an ensemble when reconstructing, and returns its dataset and the writer for its output, with a function closing it
+func (a autoencoderFlags) open(networkName string, reconstruct bool) (*m.Model, m.Dataset, *bufio.Writer, func() error) {
+	model, err := m.LoadModel(networkName)
+	if err != nil {
+		fmt.Printf("loading network for %s: %s\n", networkName, err.Error())
+		os.Exit(1)
+	}
+	if model.Mode() != m.ModeAutoencoder {
+		fmt.Printf("%s is a %s model, not an autoencoder\n", networkName, model.Mode())
+		os.Exit(1)
+	}
+	if model.Members() != nil && !reconstruct {
+		fmt.Printf("%s is an ensemble, which has no single bottleneck to encode with\n", networkName)
+		os.Exit(1)
+	}
+	if *a.data == "" {
+		*a.data = model.Name() + ".data"
+	}
+	opts := a.layout.options()
+	opts.InputNum = model.InputNum()
+	opts.OutputNum = model.TargetColumns()
+	if isFlagSet(a.flags, "outputs") {
+		opts.OutputNum = *a.outputs
+	}
+	dataset := m.FileDataset{
+		Path:   *a.data,
+		Format: opts,
+	}
+
+	var file io.WriteCloser = os.Stdout
+	if *a.out != "-" {
+		file, err = os.Create(*a.out)
+		if err != nil {
+			fmt.Printf("creating %s: %s\n", *a.out, err.Error())
+			os.Exit(1)
+		}
+	}
+	w := bufio.NewWriter(file)
+	return model, dataset, w, func() error {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if file == os.Stdout {
+			return nil
+		}
+		return file.Close()
+	}
+}
+
+// encodeCommand writes the outputs of an autoencoder's bottleneck for every line of a data file, followed by the
+// line's targets, as a data file that another network can be trained on
+func encodeCommand(networkName string, args []string) {
+	encodeFlags := flag.NewFlagSet("encode", flag.ContinueOnError)
+	flags := addAutoencoderFlags(encodeFlags)
+	err := encodeFlags.Parse(args)
+	if err != nil {
+		fmt.Printf("parsing encode flags: %s\n", err.Error())
+		os.Exit(1)
+	}
+	model, dataset, w, closeOut := flags.open(networkName, false)
+
+	var lines int
+	err = dataset.Each(func(line m.Line) error {
+		if err := model.CheckInput(line.Inputs); err != nil {
+			return err
+		}
+		lines++
+		return m.WriteLines(w, m.Lines{{Inputs: model.Encode(line.Inputs), Targets: line.Targets}})
+	})
+	if err == nil {
+		err = closeOut()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "encoding %s: %s\n", *flags.data, err.Error())
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "encoded %d lines\n", lines)
+}
+
+// reconstructCommand writes an autoencoder's reconstruction of every line of a data file, followed by the line's
+// targets. With -noise, each line is corrupted first, so a denoising autoencoder can be seen cleaning it up.
+func reconstructCommand(networkName string, args []string) {
+	reconstructFlags := flag.NewFlagSet("reconstruct", flag.ContinueOnError)
+	flags := addAutoencoderFlags(reconstructFlags)
+	flagNoise := reconstructFlags.Float64("noise", 0, "noise is the standard deviation of random noise added to each input, kept within 0..1, before it's reconstructed")
+	flagSeed := reconstructFlags.Int64("seed", 0, "seed for the noise (default is the current time)")
+	err := reconstructFlags.Parse(args)
+	if err != nil {
+		fmt.Printf("parsing reconstruct flags: %s\n", err.Error())
+		os.Exit(1)
+	}
+	seed := *flagSeed
+	if seed == 0 {
+		seed = time.Now().UTC().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+	model, dataset, w, closeOut := flags.open(networkName, true)
+
+	// the squared errors of the reconstructions, and of the noisy inputs, are measured against the original inputs
+	var lines, values int
+	var reconstructed, noisy float64
+	err = dataset.Each(func(line m.Line) error {
+		if err := model.CheckInput(line.Inputs); err != nil {
+			return err
+		}
+		inputs := line.Inputs
+		if *flagNoise > 0 {
+			inputs = make([]float64, len(line.Inputs))
+			for i, v := range line.Inputs {
+				inputs[i] = math.Max(0, math.Min(1, v+rng.NormFloat64()**flagNoise))
+				noisy += (inputs[i] - v) * (inputs[i] - v)
+			}
+		}
+		reconstruction := model.Reconstruct(inputs)
+		for i, v := range line.Inputs {
+			reconstructed += (reconstruction[i] - v) * (reconstruction[i] - v)
+		}
+		lines++
+		values += len(line.Inputs)
+		return m.WriteLines(w, m.Lines{{Inputs: reconstruction, Targets: line.Targets}})
+	})
+	if err == nil {
+		err = closeOut()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reconstructing %s: %s\n", *flags.data, err.Error())
+		os.Exit(1)
+	}
+	if lines == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "reconstructed %d lines, RMSE %.5f against the inputs\n", lines,
+		math.Sqrt(reconstructed/float64(values)))
+	if *flagNoise > 0 {
+		fmt.Fprintf(os.Stderr, "the noisy inputs had RMSE %.5f (seed %d)\n", math.Sqrt(noisy/float64(values)), seed)
+	}
+}
@@ -9,7 +9,7 @@ import (
 type Lines []string
 
 func (l Lines) WriteTo(filename string) error {
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY, 0644)
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		return fmt.Errorf("failed creating file: %w", err)
 	}
@@ -31,15 +31,3 @@ func (l Lines) WriteTo(filename string) error {
 
 	return nil
 }
-
-func normalize(min, max, input float64) float64 {
-	numerator := input - min
-	rng := max - min
-	return numerator / rng
-}
-
-func normalize2(min, max, input float64) float64 {
-	avg := (min + max) / 2
-	rng := (max - min) / 2
-	return (input - avg) / rng
-}
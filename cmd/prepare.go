@@ -1,20 +1,34 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"github.com/PaluMacil/gophernet/m"
 	"os"
+	"strconv"
 	"strings"
 )
 
-// This purpose of this script is to pre-process the data specific to the digits data. It splits
-// the target value into 10 (0 to 9). It will write the original input filename with the number 2
-// on the end. After manual examination you can replace the file and never run this again.
+// This purpose of this script is to pre-process a raw dataset described by a schema file (see data/raw for the
+// digits and fishing schemas). Numbers are normalized, categories are encoded as ordinal or one-hot values and the
+// target is split into one column per category. It will write the original input filename with the number 2 on
+// the end (or the -out file) along with the fitted schema as a .transform.json file, which is saved with trained
+// models so that predictions can be made from raw values. After manual examination you can replace the file and
+// never run this again.
 func main() {
-	if len(os.Args) != 2 {
-		fmt.Printf("command requires input filename")
+	flagSchema := flag.String("schema", "", "schema is the JSON file describing the columns of the input file")
+	flagOut := flag.String("out", "", "out is the file to write (default is the input filename with .2 on the end)")
+	flag.Parse()
+	if flag.NArg() != 1 || *flagSchema == "" {
+		fmt.Printf("command requires a -schema file and an input filename")
+		os.Exit(1)
+	}
+	filename := flag.Arg(0)
+	schema, err := m.ReadSchema(*flagSchema)
+	if err != nil {
+		fmt.Printf("reading schema: %s", err)
 		os.Exit(1)
 	}
-	filename := os.Args[1]
 	file, err := os.Open(filename)
 	if err != nil {
 		fmt.Printf("opening input file: %s", err)
@@ -22,28 +36,58 @@ func main() {
 	}
 	defer file.Close()
 
-	var newLines Lines
-	// preparation is hardcoded to look for this being either fishing or digits
-	baseFilename := strings.Split(filename, ".")[0]
-	if baseFilename == "digits" || baseFilename == "digits-test" {
-		newLines, err = prepareDigits(file)
-		if err != nil {
-			fmt.Printf("preparing digits: %s", err)
-			os.Exit(1)
-		}
-	} else {
-		newLines, err = prepareFishing(file)
-		if err != nil {
-			fmt.Printf("preparing fishing: %s", err)
-			os.Exit(1)
-		}
+	newLines, fitted, err := prepare(schema, file)
+	if err != nil {
+		fmt.Printf("preparing %s: %s", filename, err)
+		os.Exit(1)
 	}
 
-	newFilename := filename + ".2"
+	newFilename := *flagOut
+	if newFilename == "" {
+		newFilename = filename + ".2"
+	}
 	err = newLines.WriteTo(newFilename)
 	if err != nil {
 		fmt.Printf("writing output file: %s", err)
 		os.Exit(1)
 	}
-	fmt.Printf("wrote %d lines to %s", len(newLines), newFilename)
+	// training and prediction use the fitted schema to encode raw values the same way
+	err = m.WriteSchema(m.TransformPath(newFilename), fitted)
+	if err != nil {
+		fmt.Printf("writing transform: %s", err)
+		os.Exit(1)
+	}
+	fmt.Printf("wrote %d lines with %d inputs and %d outputs to %s", len(newLines),
+		schema.InputNum(), schema.OutputNum(), newFilename)
+}
+
+func prepare(schema m.Schema, file *os.File) (Lines, m.Schema, error) {
+	rows, lineNums, err := schema.ReadRows(file)
+	if err != nil {
+		return nil, m.Schema{}, fmt.Errorf("reading rows: %w", err)
+	}
+	schema, err = schema.Fit(rows)
+	if err != nil {
+		return nil, m.Schema{}, fmt.Errorf("fitting schema: %w", err)
+	}
+	var lines Lines
+	for i, row := range rows {
+		inputs, targets, err := schema.Encode(row)
+		if m.IsMissingTarget(err) {
+			fmt.Printf("skipping unlabeled line %d\n", lineNums[i])
+			continue
+		}
+		if err != nil {
+			return nil, m.Schema{}, fmt.Errorf("encoding line %d: %w", lineNums[i], err)
+		}
+		values := make([]string, 0, len(inputs)+len(targets))
+		for _, in := range inputs {
+			values = append(values, strconv.FormatFloat(in, 'f', -1, 32))
+		}
+		for _, t := range targets {
+			values = append(values, strconv.FormatFloat(t, 'f', -1, 32))
+		}
+		lines = append(lines, strings.Join(values, " "))
+	}
+	return lines, schema, nil
 }
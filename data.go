@@ -0,0 +1,298 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/PaluMacil/gophernet/m"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// dataFlags are the flags describing the layout of data files, shared by the commands that read them
+type dataFlags struct {
+	format        *string
+	labelsFile    *string
+	delimiter     *string
+	header        *bool
+	comment       *string
+	inputColumns  *string
+	targetColumns *string
+}
+
+func addDataFlags(flags *flag.FlagSet) dataFlags {
+	return dataFlags{
+		format:        flags.String("format", m.FormatText, "format of the data files: text, idx (MNIST images with a paired label file), libsvm or sequence (steps separated by semicolons)"),
+		labelsFile:    flags.String("labels-file", "", "labels-file is the IDX label file for the data (default follows the MNIST naming, e.g. train-labels-idx1-ubyte)"),
+		delimiter:     flags.String("delimiter", m.DelimiterSpace, "delimiter separates the values of text data files: space, csv or tsv"),
+		header:        flags.Bool("header", false, "header means the first row of the data files names the columns"),
+		comment:       flags.String("comment", "", "comment starts lines of the data files to ignore, such as #"),
+		inputColumns:  flags.String("input-columns", "", "input-columns is a comma separated list of header names to use as inputs (default is every column that isn't a target)"),
+		targetColumns: flags.String("target-columns", "", "target-columns is a comma separated list of header names to use as targets"),
+	}
+}
+
+// options returns the layout of the data files without their sizes
+func (d dataFlags) options() m.ReadOptions {
+	opts := m.ReadOptions{
+		Format:     *d.format,
+		LabelsPath: *d.labelsFile,
+		Delimiter:  *d.delimiter,
+		Header:     *d.header,
+		Comment:    *d.comment,
+	}
+	if *d.inputColumns != "" {
+		opts.InputColumns = strings.Split(*d.inputColumns, ",")
+	}
+	if *d.targetColumns != "" {
+		opts.TargetColumns = strings.Split(*d.targetColumns, ",")
+	}
+	return opts
+}
+
+func dataCommand(args []string) {
+	if len(args) < 2 {
+		fmt.Println("a data command and file must be specified, such as data inspect digits.data")
+		os.Exit(1)
+	}
+	subCommand, filename := args[0], args[1]
+	switch subCommand {
+	case "inspect":
+		inspectCommand(filename, args[2:])
+	case "split":
+		splitCommand(filename, args[2:])
+	default:
+		fmt.Printf("unknown data command %s\n", subCommand)
+		os.Exit(1)
+	}
+}
+
+func inspectCommand(filename string, args []string) {
+	inspectFlags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	flagInputs := inspectFlags.Int("inputs", 0, "inputs is the number of input columns")
+	flagOutputs := inspectFlags.Int("outputs", 0, "outputs is the number of target columns")
+	flagLabels := inspectFlags.String("labels", "", "labels is a comma separated list naming each target (required for libsvm)")
+	flagMin := inspectFlags.Float64("min", 0, "min is the lowest value inputs are expected to be normalized to")
+	flagMax := inspectFlags.Float64("max", 1, "max is the highest value inputs are expected to be normalized to")
+	data := addDataFlags(inspectFlags)
+	err := inspectFlags.Parse(args)
+	if err != nil {
+		fmt.Printf("parsing inspect flags: %s\n", err.Error())
+		os.Exit(1)
+	}
+	opts := data.options()
+	opts.InputNum = *flagInputs
+	opts.OutputNum = *flagOutputs
+	if *flagLabels != "" {
+		opts.Labels = strings.Split(*flagLabels, ",")
+		if opts.OutputNum == 0 {
+			opts.OutputNum = len(opts.Labels)
+		}
+	}
+	if opts.InputNum == 0 || opts.OutputNum == 0 {
+		fmt.Println("the number of inputs and outputs must be given with -inputs and -outputs")
+		os.Exit(1)
+	}
+
+	inspection, err := m.Inspect(m.FileDataset{
+		Path:   filename,
+		Format: opts,
+	}, opts.InputNum, opts.OutputNum, *flagMin, *flagMax)
+	if err != nil {
+		fmt.Printf("inspecting %s: %s\n", filename, err.Error())
+		os.Exit(1)
+	}
+	err = inspection.Report(os.Stdout, opts.Labels)
+	if err != nil {
+		fmt.Printf("writing report: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func splitCommand(filename string, args []string) {
+	base := strings.TrimSuffix(path.Base(filename), path.Ext(filename))
+	splitFlags := flag.NewFlagSet("split", flag.ContinueOnError)
+	flagInputs := splitFlags.Int("inputs", 0, "inputs is the number of input columns")
+	flagOutputs := splitFlags.Int("outputs", 0, "outputs is the number of target columns")
+	flagLabels := splitFlags.String("labels", "", "labels is a comma separated list naming each target (required for libsvm)")
+	flagTest := splitFlags.Float64("test", 0.2, "test is the fraction of lines written to the test file")
+	flagStratify := splitFlags.Bool("stratify", false, "stratify keeps the class ratios of the file in both the train and test files")
+	flagBalance := splitFlags.String("balance", "", "balance is oversample or undersample to even out the classes of the train file")
+	flagSeed := splitFlags.Int64("seed", 0, "seed for shuffling (default is the current time)")
+	flagName := splitFlags.String("name", base, "name is the dataset the files are written for")
+	flagTrainOut := splitFlags.String("train-out", "", "train-out is where the train file is written (default is <name>.train.data)")
+	flagTestOut := splitFlags.String("test-out", "", "test-out is where the test file is written (default is data/test/<name>.data)")
+	flagForce := splitFlags.Bool("force", false, "force overwrites existing train and test files")
+	data := addDataFlags(splitFlags)
+	err := splitFlags.Parse(args)
+	if err != nil {
+		fmt.Printf("parsing split flags: %s\n", err.Error())
+		os.Exit(1)
+	}
+	opts := data.options()
+	opts.InputNum = *flagInputs
+	opts.OutputNum = *flagOutputs
+	if *flagLabels != "" {
+		opts.Labels = strings.Split(*flagLabels, ",")
+		if opts.OutputNum == 0 {
+			opts.OutputNum = len(opts.Labels)
+		}
+	}
+	if opts.InputNum == 0 || opts.OutputNum == 0 {
+		fmt.Println("the number of inputs and outputs must be given with -inputs and -outputs")
+		os.Exit(1)
+	}
+	// the train file isn't named <name>.data by default, which would be the file being split when it's in the
+	// working directory
+	trainOut := *flagTrainOut
+	if trainOut == "" {
+		trainOut = *flagName + ".train.data"
+	}
+	testOut := *flagTestOut
+	if testOut == "" {
+		testOut = path.Join("data", "test", *flagName+".data")
+	}
+	outs := []string{trainOut, testOut}
+	// a file prepared from a schema has its fitted transform beside it, which is copied beside the train file so that
+	// train finds it there too
+	transformIn, transformOut := m.TransformPath(filename), m.TransformPath(trainOut)
+	if _, err := os.Stat(transformIn); err != nil {
+		transformIn = ""
+	} else {
+		outs = append(outs, transformOut)
+	}
+	for _, out := range outs {
+		same, err := samePath(filename, out)
+		if err != nil {
+			fmt.Printf("checking %s: %s\n", out, err.Error())
+			os.Exit(1)
+		}
+		if same {
+			fmt.Printf("%s is the file being split or its transform, use -train-out and -test-out to write elsewhere\n",
+				out)
+			os.Exit(1)
+		}
+	}
+	if !*flagForce {
+		for _, out := range outs {
+			if _, err := os.Stat(out); err == nil {
+				fmt.Printf("%s already exists, use -force to overwrite it\n", out)
+				os.Exit(1)
+			}
+		}
+	}
+	seed := *flagSeed
+	if seed == 0 {
+		seed = time.Now().UTC().UnixNano()
+	}
+
+	lines, err := m.ReadAll(m.FileDataset{
+		Path:   filename,
+		Format: opts,
+	})
+	if err != nil {
+		fmt.Printf("reading %s: %s\n", filename, err.Error())
+		os.Exit(1)
+	}
+	train, test, err := m.Split(lines, m.SplitOptions{
+		Test:     *flagTest,
+		Stratify: *flagStratify,
+		Balance:  *flagBalance,
+		Rng:      rand.New(rand.NewSource(seed)),
+	})
+	if err != nil {
+		fmt.Printf("splitting %s: %s\n", filename, err.Error())
+		os.Exit(1)
+	}
+	for _, out := range []struct {
+		filename string
+		lines    m.Lines
+	}{{trainOut, train}, {testOut, test}} {
+		err = writeLinesFile(out.filename, out.lines, opts)
+		if err != nil {
+			fmt.Printf("writing %s: %s\n", out.filename, err.Error())
+			os.Exit(1)
+		}
+	}
+	if transformIn != "" {
+		err = copyFile(transformIn, transformOut)
+		if err != nil {
+			fmt.Printf("copying transform: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+	fmt.Printf("wrote %d lines to %s and %d lines to %s (seed %d)\n", len(train), trainOut, len(test), testOut, seed)
+	fmt.Printf("train on them with: ./gophernet train %s -data=%s -test=%s %s\n", *flagName, trainOut, testOut,
+		readBackFlags(opts))
+}
+
+// readBackFlags are the train flags that read the files written by split, which are always space separated values
+// without a header or comments, whatever the layout of the file that was split. Text files have their inputs
+// followed by their targets and sequences keep their steps.
+func readBackFlags(opts m.ReadOptions) string {
+	flags := []string{fmt.Sprintf("-input=%d", opts.InputNum), fmt.Sprintf("-output=%d", opts.OutputNum)}
+	if opts.Format == m.FormatSequence {
+		flags = append(flags, "-format="+m.FormatSequence)
+	}
+	if len(opts.Labels) > 0 {
+		flags = append(flags, "-labels="+strings.Join(opts.Labels, ","))
+	}
+	return strings.Join(flags, " ")
+}
+
+// copyFile copies a file's contents to another file, replacing it if it exists
+func copyFile(from, to string) error {
+	data, err := ioutil.ReadFile(from)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(to, data, 0644)
+}
+
+// samePath reports whether two paths name the same file, either by resolving to the same absolute path or, when both
+// exist, by being links to the same file
+func samePath(a, b string) (bool, error) {
+	absA, err := filepath.Abs(a)
+	if err != nil {
+		return false, err
+	}
+	absB, err := filepath.Abs(b)
+	if err != nil {
+		return false, err
+	}
+	if absA == absB {
+		return true, nil
+	}
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	if errA != nil || errB != nil {
+		return false, nil
+	}
+	return os.SameFile(infoA, infoB), nil
+}
+
+// writeLinesFile writes lines as text, or as sequences when they were read from sequences
+func writeLinesFile(filename string, lines m.Lines, opts m.ReadOptions) error {
+	err := os.MkdirAll(path.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if opts.Format == m.FormatSequence {
+		err = m.WriteSequences(file, lines, opts.InputNum)
+	} else {
+		err = m.WriteLines(file, lines)
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/PaluMacil/gophernet/m"
+	"os"
+	"path"
+)
+
+func evaluateCommand(networkName string, args []string) {
+	evaluateFlags := flag.NewFlagSet("evaluate", flag.ContinueOnError)
+	flagData := evaluateFlags.String("data", "", "data is the labelled file to evaluate against (default is data/test/<dataset>.data)")
+	flagOutputs := evaluateFlags.Int("outputs", 0, "outputs is the number of target columns after the inputs of an autoencoder's data, which are ignored (default is the number the autoencoder was trained with)")
+	data := addDataFlags(evaluateFlags)
+	flagThreshold := evaluateFlags.String("threshold", "", "threshold overrides the output above which a label is predicted by a multilabel model, or a comma separated threshold per label")
+	err := evaluateFlags.Parse(args)
+	if err != nil {
+		fmt.Printf("parsing evaluate flags: %s\n", err.Error())
+		os.Exit(1)
+	}
+	model, err := m.LoadModel(networkName)
+	if err != nil {
+		fmt.Printf("loading network for %s: %s\n", networkName, err.Error())
+		os.Exit(1)
+	}
+	if *flagThreshold != "" {
+		model = withThresholds(model, *flagThreshold)
+	}
+	filename := *flagData
+	if filename == "" {
+		filename = path.Join("data", "test", model.Name()+".data")
+	}
+
+	opts := data.options()
+	if model.Sequence() {
+		opts.Format = m.FormatSequence
+	}
+	opts.InputNum = model.InputNum()
+	opts.OutputNum = model.OutputNum()
+	if model.Mode() == m.ModeAutoencoder {
+		opts.OutputNum = model.TargetColumns()
+		if isFlagSet(evaluateFlags, "outputs") {
+			opts.OutputNum = *flagOutputs
+		}
+	}
+	opts.Labels = model.Labels()
+	dataset := m.FileDataset{
+		Path:   filename,
+		Format: opts,
+	}
+	// each member of an ensemble is evaluated on its own first, to compare the ensemble with
+	for _, member := range model.Members() {
+		printEvaluation("Run "+member.Run(), member, evaluate(member, dataset))
+	}
+	title := "Run " + model.Run()
+	if members := model.Members(); members != nil {
+		title = fmt.Sprintf("Ensemble of %d runs (%s)", len(members), model.Combination())
+	}
+	printEvaluation(title, model, evaluate(model, dataset))
+}
+
+func evaluate(model *m.Model, dataset m.FileDataset) m.Evaluation {
+	evaluation, err := model.Evaluate(dataset)
+	if err != nil {
+		fmt.Printf("evaluating %s: %s\n", dataset.Path, err.Error())
+		os.Exit(1)
+	}
+	return evaluation
+}
+
+func printEvaluation(title string, model *m.Model, evaluation m.Evaluation) {
+	if model.Mode() == m.ModeMultiLabel {
+		fmt.Printf("%s: %d of %d with every label correct, subset accuracy %.2f%%, Hamming loss %.5f, micro F1 %.5f, macro F1 %.5f\n",
+			title, evaluation.Correct, evaluation.Total, evaluation.Accuracy, evaluation.HammingLoss,
+			evaluation.MicroF1, evaluation.MacroF1)
+		return
+	}
+	if model.Mode() == m.ModeRegression || model.Mode() == m.ModeAutoencoder {
+		fmt.Printf("%s: %d lines, MAE %.5f, RMSE %.5f, R² %.5f\n",
+			title, evaluation.Total, evaluation.MAE, evaluation.RMSE, evaluation.R2)
+		return
+	}
+	fmt.Printf("%s: %d of %d correct, accuracy %.2f%%\n",
+		title, evaluation.Correct, evaluation.Total, evaluation.Accuracy)
+}
@@ -54,3 +54,123 @@ func (t Tanh) Deactivate(matrix mat.Matrix) mat.Matrix {
 func (t Tanh) String() string {
 	return "tanh"
 }
+
+// Softmax turns the weighted sums of a layer into a probability distribution. It's used for the output layer of a
+// classifier trained with categorical cross-entropy, whose outputs are then calibrated probabilities.
+type Softmax struct{}
+
+// Activate is the unnormalized exponential of a single sum, which ActivateAll divides by the total of the layer
+func (s Softmax) Activate(i, j int, sum float64) float64 {
+	return math.Exp(sum)
+}
+
+// ActivateAll exponentiates each sum less the largest, which leaves the result unchanged but can't overflow, and
+// divides by their total
+func (s Softmax) ActivateAll(sums mat.Matrix) mat.Matrix {
+	max := mat.Max(sums)
+	exps := apply(func(i, j int, v float64) float64 {
+		return math.Exp(v - max)
+	}, sums)
+	return scale(1/mat.Sum(exps), exps)
+}
+
+// Deactivate is the diagonal of the softmax's Jacobian. The cross-entropy error already includes the full derivative,
+// so it's only used with other losses.
+func (s Softmax) Deactivate(matrix mat.Matrix) mat.Matrix {
+	return Sigmoid{}.Deactivate(matrix)
+}
+
+// Probabilities returns a copy of the outputs, which already sum to one
+func (s Softmax) Probabilities(outputs []float64) []float64 {
+	return append([]float64(nil), outputs...)
+}
+
+func (s Softmax) String() string {
+	return "softmax"
+}
+
+// LayerActivator is implemented by activators whose outputs depend on every weighted sum of a layer, such as softmax
+type LayerActivator interface {
+	ActivateAll(sums mat.Matrix) mat.Matrix
+}
+
+// Linear passes weighted sums through unchanged. It's used for the output layer in regression mode, where outputs
+// aren't limited to the range of the hidden activator.
+type Linear struct{}
+
+func (l Linear) Activate(i, j int, sum float64) float64 {
+	return sum
+}
+
+func (l Linear) Deactivate(matrix mat.Matrix) mat.Matrix {
+	rows, cols := matrix.Dims()
+	ones := mat.NewDense(rows, cols, nil)
+	ones.Apply(func(i, j int, v float64) float64 {
+		return 1
+	}, ones)
+	return ones
+}
+
+func (l Linear) String() string {
+	return "linear"
+}
+
+// Activation is a layer applying an activator to each of its inputs
+type Activation struct {
+	activator Activator
+	// lossDerivative is set at an output whose loss's error already includes the activator's derivative, as the
+	// cross-entropy error does for a sigmoid or softmax
+	lossDerivative bool
+	outputs        mat.Matrix
+}
+
+// NewActivation returns an activation layer for an activator
+func NewActivation(activator Activator) *Activation {
+	return &Activation{activator: activator}
+}
+
+// Forward activates each input
+func (a *Activation) Forward(inputs mat.Matrix, training bool) mat.Matrix {
+	var outputs mat.Matrix
+	if layer, ok := a.activator.(LayerActivator); ok {
+		outputs = layer.ActivateAll(inputs)
+	} else {
+		outputs = apply(a.activator.Activate, inputs)
+	}
+	if training {
+		a.outputs = outputs
+	}
+	return outputs
+}
+
+// Backward passes the error back unchanged and takes the gradient from it, scaled by the activator's derivative
+func (a *Activation) Backward(errors Errors) Errors {
+	if a.lossDerivative {
+		return Errors{Error: errors.Error, Gradient: errors.Error}
+	}
+	return Errors{Error: errors.Error, Gradient: multiply(errors.Error, a.activator.Deactivate(a.outputs))}
+}
+
+// Parameters is empty since an activation doesn't learn
+func (a *Activation) Parameters() []*Parameter {
+	return nil
+}
+
+// State is empty
+func (a *Activation) State() map[string]*mat.Dense {
+	return nil
+}
+
+// SetState does nothing
+func (a *Activation) SetState(state map[string]*mat.Dense) error {
+	return nil
+}
+
+// Activator is the layer's activator
+func (a *Activation) Activator() Activator {
+	return a.activator
+}
+
+func (a *Activation) String() string {
+	return a.activator.String()
+}
@@ -0,0 +1,192 @@
+package m
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Augmentation describes random distortions of image inputs, such as the 8x8 digit bitmaps, applied afresh every
+// time a line is read so that each epoch sees slightly different images. The zero value of each field disables it.
+type Augmentation struct {
+	// Width and Height are the size of the image, whose rows of pixels make up the inputs
+	Width  int
+	Height int
+	// Shift is the most whole pixels an image is moved in each direction
+	Shift int
+	// Rotate is the most degrees an image is rotated either way around its center
+	Rotate float64
+	// Noise is the standard deviation of Gaussian noise added to each intensity
+	Noise float64
+	// Elastic is how many pixels an elastic distortion moves the point it moves furthest, and Sigma is how smoothly
+	// the distortion varies across the image. Sigma defaults to 1.
+	Elastic float64
+	Sigma   float64
+	// Low and High are the range intensities are kept within, which is also the background filled in at the edges
+	Low  float64
+	High float64
+}
+
+// Check reports whether an augmentation fits inputs of the given size
+func (a Augmentation) Check(inputNum int) error {
+	if a.Width <= 0 || a.Height <= 0 {
+		return fmt.Errorf("image size must be positive, got %dx%d", a.Width, a.Height)
+	}
+	if a.Width*a.Height != inputNum {
+		return fmt.Errorf("a %dx%d image has %d pixels, expected %d inputs", a.Width, a.Height, a.Width*a.Height, inputNum)
+	}
+	if a.Shift < 0 || a.Rotate < 0 || a.Noise < 0 || a.Elastic < 0 || a.Sigma < 0 {
+		return fmt.Errorf("augmentation amounts cannot be negative")
+	}
+	if a.Low >= a.High {
+		return fmt.Errorf("intensity range %g..%g is empty", a.Low, a.High)
+	}
+	return nil
+}
+
+type augmented struct {
+	dataset      Dataset
+	augmentation Augmentation
+	rng          *rand.Rand
+}
+
+// Augmented distorts the inputs of every line of a dataset each time it's iterated. Targets are left alone. The
+// same rng seed gives the same distortions.
+func Augmented(dataset Dataset, augmentation Augmentation, rng *rand.Rand) Dataset {
+	if augmentation.Sigma == 0 {
+		augmentation.Sigma = 1
+	}
+	return augmented{
+		dataset:      dataset,
+		augmentation: augmentation,
+		rng:          rng,
+	}
+}
+
+func (a augmented) Each(fn func(Line) error) error {
+	return a.dataset.Each(func(line Line) error {
+		if len(line.Inputs) != a.augmentation.Width*a.augmentation.Height {
+			return fmt.Errorf("expected %d inputs for a %dx%d image, got %d", a.augmentation.Width*a.augmentation.Height,
+				a.augmentation.Width, a.augmentation.Height, len(line.Inputs))
+		}
+		return fn(Line{
+			Inputs:  a.augmentation.apply(line.Inputs, a.rng),
+			Targets: line.Targets,
+		})
+	})
+}
+
+// apply returns a distorted copy of an image. Each output pixel is sampled from the point of the original that the
+// rotation, shift and elastic displacement move onto it.
+func (a Augmentation) apply(image []float64, rng *rand.Rand) []float64 {
+	var shiftX, shiftY float64
+	if a.Shift > 0 {
+		shiftX = float64(rng.Intn(2*a.Shift+1) - a.Shift)
+		shiftY = float64(rng.Intn(2*a.Shift+1) - a.Shift)
+	}
+	var angle float64
+	if a.Rotate > 0 {
+		angle = (rng.Float64()*2 - 1) * a.Rotate * math.Pi / 180
+	}
+	var dx, dy []float64
+	if a.Elastic > 0 {
+		dx = a.displacement(rng)
+		dy = a.displacement(rng)
+	}
+
+	sin, cos := math.Sin(-angle), math.Cos(-angle)
+	centerX, centerY := float64(a.Width-1)/2, float64(a.Height-1)/2
+	distorted := make([]float64, len(image))
+	for y := 0; y < a.Height; y++ {
+		for x := 0; x < a.Width; x++ {
+			i := y*a.Width + x
+			// undo the shift and rotation to find where this pixel came from
+			px, py := float64(x)-shiftX-centerX, float64(y)-shiftY-centerY
+			sourceX := px*cos - py*sin + centerX
+			sourceY := px*sin + py*cos + centerY
+			if dx != nil {
+				sourceX += dx[i]
+				sourceY += dy[i]
+			}
+			v := a.sample(image, sourceX, sourceY)
+			if a.Noise > 0 {
+				v += rng.NormFloat64() * a.Noise
+			}
+			distorted[i] = math.Max(a.Low, math.Min(a.High, v))
+		}
+	}
+	return distorted
+}
+
+// sample interpolates between the four pixels around a point, treating anything outside the image as background
+func (a Augmentation) sample(image []float64, x, y float64) float64 {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+	pixel := func(x, y int) float64 {
+		if x < 0 || y < 0 || x >= a.Width || y >= a.Height {
+			return a.Low
+		}
+		return image[y*a.Width+x]
+	}
+	ix, iy := int(x0), int(y0)
+	top := pixel(ix, iy)*(1-fx) + pixel(ix+1, iy)*fx
+	bottom := pixel(ix, iy+1)*(1-fx) + pixel(ix+1, iy+1)*fx
+	return top*(1-fy) + bottom*fy
+}
+
+// displacement is a random field smoothed with a Gaussian and scaled so that its largest move is Elastic pixels
+func (a Augmentation) displacement(rng *rand.Rand) []float64 {
+	field := make([]float64, a.Width*a.Height)
+	for i := range field {
+		field[i] = rng.Float64()*2 - 1
+	}
+	radius := int(math.Ceil(3 * a.Sigma))
+	kernel := make([]float64, 2*radius+1)
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = math.Exp(-d * d / (2 * a.Sigma * a.Sigma))
+	}
+	// blur the rows and then the columns, clamping at the edges
+	blur := func(field []float64, horizontal bool) []float64 {
+		blurred := make([]float64, len(field))
+		for y := 0; y < a.Height; y++ {
+			for x := 0; x < a.Width; x++ {
+				var sum, weight float64
+				for k, w := range kernel {
+					sx, sy := x, y
+					if horizontal {
+						sx = clampInt(x+k-radius, 0, a.Width-1)
+					} else {
+						sy = clampInt(y+k-radius, 0, a.Height-1)
+					}
+					sum += w * field[sy*a.Width+sx]
+					weight += w
+				}
+				blurred[y*a.Width+x] = sum / weight
+			}
+		}
+		return blurred
+	}
+	field = blur(blur(field, true), false)
+
+	var largest float64
+	for _, v := range field {
+		largest = math.Max(largest, math.Abs(v))
+	}
+	if largest > 0 {
+		for i := range field {
+			field[i] *= a.Elastic / largest
+		}
+	}
+	return field
+}
+
+func clampInt(v, low, high int) int {
+	if v < low {
+		return low
+	}
+	if v > high {
+		return high
+	}
+	return v
+}
@@ -0,0 +1,134 @@
+package m
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestAugmentationCheck(t *testing.T) {
+	valid := Augmentation{Width: 2, Height: 3, Shift: 1, Rotate: 10, Noise: 0.1, Elastic: 1, Sigma: 2, High: 1}
+	if err := valid.Check(6); err != nil {
+		t.Fatalf("%+v: %s", valid, err)
+	}
+	tests := []struct {
+		name   string
+		modify func(*Augmentation)
+		inputs int
+	}{
+		{"wrong size", func(a *Augmentation) {}, 7},
+		{"zero width", func(a *Augmentation) { a.Width = 0 }, 0},
+		{"negative height", func(a *Augmentation) { a.Height = -3 }, 6},
+		{"negative shift", func(a *Augmentation) { a.Shift = -1 }, 6},
+		{"negative rotation", func(a *Augmentation) { a.Rotate = -5 }, 6},
+		{"negative noise", func(a *Augmentation) { a.Noise = -0.1 }, 6},
+		{"negative elastic", func(a *Augmentation) { a.Elastic = -1 }, 6},
+		{"negative sigma", func(a *Augmentation) { a.Sigma = -1 }, 6},
+		{"empty range", func(a *Augmentation) { a.Low = 1 }, 6},
+		{"inverted range", func(a *Augmentation) { a.Low, a.High = 1, 0 }, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := valid
+			tt.modify(&a)
+			if err := a.Check(tt.inputs); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+// digit is a 4x4 image with a bright vertical stroke
+var digit = Lines{{
+	Inputs:  []float64{0, 1, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0},
+	Targets: []float64{0, 1},
+}}
+
+func TestAugmented(t *testing.T) {
+	augmentation := Augmentation{Width: 4, Height: 4, Shift: 1, Rotate: 15, Noise: 0.05, Elastic: 0.5, High: 1}
+	augment := func(seed int64) Lines {
+		lines, err := ReadAll(Augmented(digit, augmentation, rand.New(rand.NewSource(seed))))
+		if err != nil {
+			t.Fatalf("augmenting: %s", err)
+		}
+		return lines
+	}
+	first := augment(1)
+	if !reflect.DeepEqual(first, augment(1)) {
+		t.Error("the same seed distorted differently")
+	}
+	if reflect.DeepEqual(first, augment(2)) {
+		t.Error("different seeds distorted the same way")
+	}
+	if reflect.DeepEqual(first[0].Inputs, digit[0].Inputs) {
+		t.Error("the image wasn't distorted")
+	}
+	if !reflect.DeepEqual(first[0].Targets, digit[0].Targets) {
+		t.Errorf("got targets %v, expected them unchanged", first[0].Targets)
+	}
+	if digit[0].Inputs[1] != 1 || digit[0].Inputs[0] != 0 {
+		t.Error("the original image was modified")
+	}
+
+	// each epoch is distorted afresh
+	dataset := Augmented(digit, augmentation, rand.New(rand.NewSource(1)))
+	epoch1, _ := ReadAll(dataset)
+	epoch2, _ := ReadAll(dataset)
+	if reflect.DeepEqual(epoch1, epoch2) {
+		t.Error("two epochs were distorted the same way")
+	}
+}
+
+func TestAugmentedWithoutDistortion(t *testing.T) {
+	lines, err := ReadAll(Augmented(digit, Augmentation{Width: 4, Height: 4, High: 1}, rand.New(rand.NewSource(1))))
+	if err != nil {
+		t.Fatalf("augmenting: %s", err)
+	}
+	if !reflect.DeepEqual(lines, digit) {
+		t.Errorf("got %v, expected the image unchanged", lines)
+	}
+}
+
+func TestAugmentedClamps(t *testing.T) {
+	for _, a := range []Augmentation{
+		{Width: 4, Height: 4, Noise: 5, Low: 0, High: 1},
+		{Width: 4, Height: 4, Noise: 5, Shift: 2, Rotate: 45, Elastic: 2, Low: -0.5, High: 0.5},
+	} {
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < 20; i++ {
+			for _, v := range a.apply(digit[0].Inputs, rng) {
+				if v < a.Low || v > a.High {
+					t.Fatalf("%+v: intensity %g is outside %g..%g", a, v, a.Low, a.High)
+				}
+			}
+		}
+	}
+}
+
+func TestAugmentedShiftFillsBackground(t *testing.T) {
+	// shifting a full image always moves some background in at the edges, which is Low
+	full := Lines{{Inputs: []float64{1, 1, 1, 1, 1, 1, 1, 1, 1}, Targets: []float64{1}}}
+	a := Augmentation{Width: 3, Height: 3, Shift: 1, Low: -1, High: 1}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		distorted := a.apply(full[0].Inputs, rng)
+		var background, lit int
+		for _, v := range distorted {
+			switch v {
+			case -1:
+				background++
+			case 1:
+				lit++
+			}
+		}
+		if background+lit != len(distorted) || (background != 0 && background != 3 && background != 5) {
+			t.Fatalf("got %v, expected whole pixels shifted in from a background of -1", distorted)
+		}
+	}
+}
+
+func TestAugmentedWrongSize(t *testing.T) {
+	if _, err := ReadAll(Augmented(digit, Augmentation{Width: 3, Height: 3, High: 1}, rand.New(rand.NewSource(1)))); err == nil {
+		t.Error("expected an error for 16 inputs to a 3x3 image")
+	}
+}
@@ -0,0 +1,76 @@
+package m
+
+import (
+	"gonum.org/v1/gonum/mat"
+	"strconv"
+)
+
+// bottleneck is the index of an autoencoder's middle layer, whose size is Bottleneck, or -1 outside autoencoder mode.
+// Any hidden layers before it encode and any after it decode.
+func (c Config) bottleneck() int {
+	if c.Mode != ModeAutoencoder {
+		return -1
+	}
+	return c.LayerNum / 2
+}
+
+// encoder is the number of layers of a Sequential composed from the config, from the input through the activation
+// of the bottleneck, which make up the autoencoder's encoder
+func (c Config) encoder(s *Sequential) int {
+	var dense int
+	for i, layer := range s.Layers {
+		switch layer.(type) {
+		case *Dense:
+			dense++
+		case *Activation:
+			if dense == c.bottleneck() {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+// InputLabels names the outputs of an autoencoder, which reconstruct the inputs, by the input columns or as x1, x2
+// and so on
+func InputLabels(inputNum int, columns []string) []string {
+	if len(columns) == inputNum {
+		return columns
+	}
+	labels := make([]string, inputNum)
+	for i := range labels {
+		labels[i] = "x" + strconv.Itoa(i+1)
+	}
+	return labels
+}
+
+// Reconstructing wraps a dataset so that the targets of every line are its inputs, which an autoencoder is trained
+// and evaluated against. Any targets read from the data are dropped.
+func Reconstructing(dataset Dataset) Dataset {
+	return reconstructing{dataset}
+}
+
+type reconstructing struct {
+	dataset Dataset
+}
+
+func (r reconstructing) Each(fn func(Line) error) error {
+	return r.dataset.Each(func(line Line) error {
+		return fn(Line{Inputs: line.Inputs, Targets: line.Inputs})
+	})
+}
+
+// Encode returns the outputs of an autoencoder's bottleneck for an input, or nil for a model that isn't an
+// autoencoder
+func (model *Model) Encode(inputData []float64) []float64 {
+	if model.encoder == 0 {
+		return nil
+	}
+	encoder := Sequential{Layers: model.sequential.Layers[:model.encoder]}
+	return mat.Col(nil, 0, encoder.Forward(mat.NewDense(len(inputData), 1, inputData), false))
+}
+
+// Reconstruct returns an autoencoder's reconstruction of an input
+func (model *Model) Reconstruct(inputData []float64) []float64 {
+	return model.outputs(inputData)
+}
@@ -0,0 +1,158 @@
+package m
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseQuery splits a row of comma or whitespace separated numbers into input values. The steps of a sequence may
+// also be separated by semicolons.
+func ParseQuery(row string) ([]float64, error) {
+	fields := strings.FieldsFunc(row, func(r rune) bool {
+		return r == ',' || r == ';' || unicode.IsSpace(r)
+	})
+	query := make([]float64, len(fields))
+	for i, field := range fields {
+		num, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing value %d: %w", i+1, err)
+		}
+		query[i] = num
+	}
+	return query, nil
+}
+
+// PredictionWriter writes the prediction made for each line of a batch.
+type PredictionWriter interface {
+	WritePrediction(lineNum int, p Prediction) error
+	Flush() error
+}
+
+type csvPredictionWriter struct {
+	w           *csv.Writer
+	labels      []string
+	wroteHeader bool
+}
+
+// NewCSVPredictionWriter writes a header followed by one record per prediction holding the line number,
+// the predicted label and the output for each label in label order.
+func NewCSVPredictionWriter(w io.Writer, labels []string) PredictionWriter {
+	return &csvPredictionWriter{
+		w:      csv.NewWriter(w),
+		labels: labels,
+	}
+}
+
+func (cw *csvPredictionWriter) WritePrediction(lineNum int, p Prediction) error {
+	if !cw.wroteHeader {
+		err := cw.w.Write(append([]string{"Line", "Label"}, cw.labels...))
+		if err != nil {
+			return fmt.Errorf("writing csv headers: %w", err)
+		}
+		cw.wroteHeader = true
+	}
+	record := make([]string, len(cw.labels)+2)
+	record[0] = strconv.Itoa(lineNum)
+	record[1] = p.Label
+	for i, label := range cw.labels {
+		for _, score := range p.Scores {
+			if score.Label == label {
+				record[i+2] = strconv.FormatFloat(score.Output, 'f', -1, 64)
+				break
+			}
+		}
+	}
+	return cw.w.Write(record)
+}
+
+func (cw *csvPredictionWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type jsonLinesPredictionWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+	top int
+}
+
+// NewJSONLinesPredictionWriter writes one JSON object per prediction, limited to the top ranked scores.
+func NewJSONLinesPredictionWriter(w io.Writer, top int) PredictionWriter {
+	bw := bufio.NewWriter(w)
+	return &jsonLinesPredictionWriter{
+		w:   bw,
+		enc: json.NewEncoder(bw),
+		top: top,
+	}
+}
+
+func (jw *jsonLinesPredictionWriter) WritePrediction(lineNum int, p Prediction) error {
+	p.Scores = p.Top(jw.top)
+	return jw.enc.Encode(struct {
+		Line int `json:"line"`
+		Prediction
+	}{lineNum, p})
+}
+
+func (jw *jsonLinesPredictionWriter) Flush() error {
+	return jw.w.Flush()
+}
+
+// PredictAll predicts every non-blank line read from r and writes the results to w. Malformed lines are
+// skipped and passed to rejected along with their line numbers. The number of rejected lines is returned.
+func (model *Model) PredictAll(r io.Reader, w PredictionWriter, rejected func(error)) (int, error) {
+	scanner := bufio.NewScanner(r)
+	var lineNum, rejects int
+	for scanner.Scan() {
+		lineNum++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		query, err := ParseQuery(scanner.Text())
+		if err != nil {
+			rejects++
+			rejected(fmt.Errorf("at line %d, %w", lineNum, err))
+			continue
+		}
+		if err := model.CheckInput(query); err != nil {
+			rejects++
+			rejected(fmt.Errorf("at line %d, %w", lineNum, err))
+			continue
+		}
+		err = w.WritePrediction(lineNum, model.PredictScores(query))
+		if err != nil {
+			return rejects, fmt.Errorf("writing prediction for line %d: %w", lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return rejects, fmt.Errorf("reading input: %w", err)
+	}
+
+	return rejects, w.Flush()
+}
+
+// PredictDataset predicts every line of a dataset, ignoring its targets, and writes the results to w numbered from 1
+func (model *Model) PredictDataset(dataset Dataset, w PredictionWriter) error {
+	var lineNum int
+	err := dataset.Each(func(line Line) error {
+		lineNum++
+		if err := model.CheckInput(line.Inputs); err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		err := w.WritePrediction(lineNum, model.PredictScores(line.Inputs))
+		if err != nil {
+			return fmt.Errorf("writing prediction for line %d: %w", lineNum, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
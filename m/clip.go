@@ -0,0 +1,124 @@
+package m
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/mat"
+	"math"
+)
+
+// What training does when an update leaves a weight that isn't a finite number
+const (
+	// OnNaNAbort stops training with an error and saves nothing
+	OnNaNAbort = "abort"
+	// OnNaNRollback restores the weights from the end of the last good epoch and stops training there, so that model
+	// is saved and analyzed instead
+	OnNaNRollback = "rollback"
+)
+
+// CheckOnNaN reports whether a response to non-finite weights exists
+func CheckOnNaN(onNaN string) error {
+	switch onNaN {
+	case "", OnNaNAbort, OnNaNRollback:
+		return nil
+	}
+	return fmt.Errorf("invalid response to non-finite weights %s, expected %s or %s", onNaN, OnNaNAbort, OnNaNRollback)
+}
+
+// NonFiniteError reports the layer that an update left with a NaN or infinite value
+type NonFiniteError struct {
+	// Layer is the index of the layer in the network and Name describes it
+	Layer int
+	Name  string
+	Epoch int
+}
+
+func (e NonFiniteError) Error() string {
+	return fmt.Sprintf("layer %d (%s) is no longer finite in epoch %d", e.Layer, e.Name, e.Epoch)
+}
+
+// clipValue limits every value of a gradient to ±ClipValue
+func (c Config) clipValue(gradient *mat.Dense) {
+	limit := c.ClipValue
+	if limit <= 0 {
+		return
+	}
+	gradient.Apply(func(_, _ int, v float64) float64 {
+		return math.Max(-limit, math.Min(limit, v))
+	}, gradient)
+}
+
+// clipNorm scales the gradients of every parameter down together when their combined norm is over ClipNorm
+func (c Config) clipNorm(parameters []*Parameter) {
+	if c.ClipNorm <= 0 {
+		return
+	}
+	var squared float64
+	for _, p := range parameters {
+		squared += math.Pow(mat.Norm(p.Gradient, 2), 2)
+	}
+	norm := math.Sqrt(squared)
+	if norm <= c.ClipNorm {
+		return
+	}
+	for _, p := range parameters {
+		p.Gradient.Scale(c.ClipNorm/norm, p.Gradient)
+	}
+}
+
+// update clips the gradients found by the last backward pass, scales them by the learning rate and adds them to the
+// parameters, which are then regularized. Clipping comes first so that a limit means the same at any learning rate.
+// It reports a NonFiniteError if a layer is left with a value that isn't finite.
+func (net *Network) update() error {
+	parameters := net.sequential.Parameters()
+	for _, p := range parameters {
+		net.config.clipValue(p.Gradient)
+	}
+	net.config.clipNorm(parameters)
+	for _, p := range parameters {
+		p.Gradient.Scale(net.config.LearningRate, p.Gradient)
+	}
+	for i, layer := range net.sequential.Layers {
+		for _, p := range layer.Parameters() {
+			p.Value.Add(p.Value, p.Gradient)
+			regularize(p, net.config.LearningRate)
+			if !finite(p.Value) {
+				return NonFiniteError{Layer: i, Name: layer.String()}
+			}
+		}
+	}
+	return nil
+}
+
+func finite(m mat.Matrix) bool {
+	rows, cols := m.Dims()
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			v := m.At(r, c)
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// checkpoint is a copy of the state of every layer at the end of an epoch
+type checkpoint struct {
+	epoch  int
+	states map[string]*mat.Dense
+}
+
+func (net *Network) checkpoint(epoch int) *checkpoint {
+	states := net.sequential.states("")
+	for filename, d := range states {
+		states[filename] = mat.DenseCopyOf(d)
+	}
+	return &checkpoint{epoch: epoch, states: states}
+}
+
+// restore puts the state of every layer in a checkpoint back
+func (net *Network) restore(cp *checkpoint) error {
+	return net.sequential.setStates("", func(filename string) (*mat.Dense, error) {
+		return cp.states[filename], nil
+	})
+}
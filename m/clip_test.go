@@ -0,0 +1,173 @@
+package m
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// withOutPath points the files a run saves at a temporary directory
+func withOutPath(t *testing.T) string {
+	t.Helper()
+	dir := tempDir(t)
+	previous := outPath
+	outPath = dir
+	t.Cleanup(func() {
+		outPath = previous
+	})
+	return dir
+}
+
+func TestClipValue(t *testing.T) {
+	gradient := mat.NewDense(1, 4, []float64{-2, 0.1, 3, -0.5})
+	Config{ClipValue: 0.5}.clipValue(gradient)
+	if expected := []float64{-0.5, 0.1, 0.5, -0.5}; !closeValues(gradient.RawMatrix().Data, expected) {
+		t.Errorf("got %v, expected %v", gradient.RawMatrix().Data, expected)
+	}
+	gradient.Set(0, 0, 100)
+	Config{}.clipValue(gradient)
+	if gradient.At(0, 0) != 100 {
+		t.Errorf("a limit of 0 clipped %g", gradient.At(0, 0))
+	}
+}
+
+func TestClipNorm(t *testing.T) {
+	parameters := func() []*Parameter {
+		return []*Parameter{
+			{Gradient: mat.NewDense(1, 2, []float64{3, 0})},
+			{Gradient: mat.NewDense(2, 1, []float64{0, -4})},
+		}
+	}
+	tests := []struct {
+		limit    float64
+		expected []float64
+	}{
+		{0, []float64{3, 0, 0, -4}},
+		{10, []float64{3, 0, 0, -4}},
+		{5, []float64{3, 0, 0, -4}},
+		// the combined norm is 5, so both gradients are scaled by 1/5 together
+		{1, []float64{0.6, 0, 0, -0.8}},
+	}
+	for _, tt := range tests {
+		ps := parameters()
+		Config{ClipNorm: tt.limit}.clipNorm(ps)
+		got := append(mat.Col(nil, 0, ps[0].Gradient.T()), mat.Col(nil, 0, ps[1].Gradient)...)
+		if !closeValues(got, tt.expected) {
+			t.Errorf("limit %g: got %v, expected %v", tt.limit, got, tt.expected)
+		}
+	}
+}
+
+// TestUpdateClipsBeforeLearningRate checks that a limit applies to the gradient, not the update, so that it means
+// the same at any learning rate
+func TestUpdateClipsBeforeLearningRate(t *testing.T) {
+	for _, rate := range []float64{0.1, 1} {
+		for _, c := range []Config{{ClipValue: 1}, {ClipNorm: 1}} {
+			c.InputNum, c.OutputNum, c.LayerNum, c.Activator, c.LearningRate = 1, 1, 2, Sigmoid{}, rate
+			net := NewNetwork(c)
+			weights := net.sequential.Parameters()[0]
+			weights.Value.Set(0, 0, 0)
+			weights.Gradient = mat.NewDense(1, 1, []float64{10})
+			if err := net.update(); err != nil {
+				t.Fatalf("updating: %s", err)
+			}
+			if got := weights.Value.At(0, 0); math.Abs(got-rate) > 1e-12 {
+				t.Errorf("%+v: a gradient of 10 clipped to 1 moved the weight by %g, expected %g", c, got, rate)
+			}
+		}
+	}
+}
+
+// epochLines is a dataset that gives each epoch its own lines, repeating the last for any later epochs
+type epochLines struct {
+	epoch  *int
+	epochs []Lines
+}
+
+func (d epochLines) Each(fn func(Line) error) error {
+	lines := d.epochs[len(d.epochs)-1]
+	if *d.epoch < len(d.epochs) {
+		lines = d.epochs[*d.epoch]
+	}
+	*d.epoch++
+	return lines.Each(fn)
+}
+
+type observerFunc func(stats EpochStats)
+
+func (f observerFunc) ObserveEpoch(stats EpochStats) {
+	f(stats)
+}
+
+// TestNonFiniteWeights trains a network whose second epoch has a NaN input, which leaves the weights NaN
+func TestNonFiniteWeights(t *testing.T) {
+	good := Lines{{Inputs: []float64{0.2, 0.8}, Targets: []float64{1, 0}}}
+	bad := Lines{{Inputs: []float64{math.NaN(), 0.8}, Targets: []float64{1, 0}}}
+	config := func(onNaN string) Config {
+		return Config{
+			Name:         "nan",
+			InputNum:     2,
+			HiddenNum:    3,
+			OutputNum:    2,
+			LayerNum:     3,
+			Epochs:       3,
+			Activator:    Sigmoid{},
+			LearningRate: 0.1,
+			TargetLabels: []string{"a", "b"},
+			OnNaN:        onNaN,
+		}
+	}
+
+	t.Run("abort", func(t *testing.T) {
+		withOutPath(t)
+		net := NewNetwork(config(OnNaNAbort))
+		var epoch int
+		err := net.Train(epochLines{epoch: &epoch, epochs: []Lines{good, bad}})
+		var nonFinite NonFiniteError
+		if !errors.As(err, &nonFinite) {
+			t.Fatalf("got %v, expected a NonFiniteError", err)
+		}
+		if nonFinite.Epoch != 2 || nonFinite.Layer != 0 {
+			t.Errorf("got %+v, expected the first layer in epoch 2", nonFinite)
+		}
+		if net.trainingEnd != 0 {
+			t.Error("an aborted run was saved")
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		withOutPath(t)
+		net := NewNetwork(config(OnNaNRollback))
+		var epoch int
+		var afterFirst map[string]*mat.Dense
+		net.config.Observer = observerFunc(func(stats EpochStats) {
+			if stats.Epoch == 1 {
+				afterFirst = net.checkpoint(stats.Epoch).states
+			}
+		})
+		if err := net.Train(epochLines{epoch: &epoch, epochs: []Lines{good, bad}}); err != nil {
+			t.Fatalf("training: %s", err)
+		}
+		if net.config.Epochs != 1 {
+			t.Errorf("recorded %d epochs, expected the 1 good one", net.config.Epochs)
+		}
+		for filename, d := range net.sequential.states("") {
+			if !finite(d) || !mat.Equal(d, afterFirst[filename]) {
+				t.Errorf("%s wasn't rolled back to the first epoch", filename)
+			}
+		}
+	})
+
+	t.Run("rollback without a good epoch", func(t *testing.T) {
+		withOutPath(t)
+		net := NewNetwork(config(OnNaNRollback))
+		var epoch int
+		err := net.Train(epochLines{epoch: &epoch, epochs: []Lines{bad}})
+		var nonFinite NonFiniteError
+		if !errors.As(err, &nonFinite) || nonFinite.Epoch != 1 {
+			t.Errorf("got %v, expected a NonFiniteError in epoch 1", err)
+		}
+	})
+}
@@ -0,0 +1,556 @@
+package m
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/mat"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Kinds of layers that can come ahead of the dense layers of a convolutional network
+const (
+	LayerConv    = "conv"
+	LayerMaxPool = "maxpool"
+	LayerAvgPool = "avgpool"
+	LayerFlatten = "flatten"
+)
+
+// Shape is the size of an image passed between convolutional layers. Its values are laid out as a column, channel by
+// channel and row by row.
+type Shape struct {
+	Channels int
+	Height   int
+	Width    int
+}
+
+// Size is the number of values in the image
+func (s Shape) Size() int {
+	return s.Channels * s.Height * s.Width
+}
+
+func (s Shape) String() string {
+	return fmt.Sprintf("%dx%dx%d", s.Channels, s.Height, s.Width)
+}
+
+// index is the position of a value in the column
+func (s Shape) index(channel, y, x int) int {
+	return (channel*s.Height+y)*s.Width + x
+}
+
+// slide is the number of positions a window of size moves to along a side of length with stride and padding, which
+// is 0 when the window is larger than the padded side
+func slide(length, size, stride, padding int) int {
+	if length+2*padding < size {
+		return 0
+	}
+	return (length+2*padding-size)/stride + 1
+}
+
+// Conv2D is a layer of filters that each slide a square kernel over every channel of an image, giving a channel of
+// outputs per filter
+type Conv2D struct {
+	in      Shape
+	out     Shape
+	kernel  int
+	stride  int
+	padding int
+	// weights holds a row per filter with a column per channel and position in the kernel
+	weights Parameter
+	// biases is nil for a layer without biases, or has a bias per filter
+	biases *Parameter
+	// patches holds the input under the kernel at each position from the last Forward while training
+	patches *mat.Dense
+}
+
+// NewConv2D returns a convolution of an image with a row of weights per filter and a column per channel and position
+// of a kernel, which is kernel wide and high and moves by stride over the image padded by zeros. biases may be nil.
+func NewConv2D(in Shape, kernel, stride, padding int, weights, biases *mat.Dense, l1, l2 float64) *Conv2D {
+	filters, _ := weights.Dims()
+	c := &Conv2D{
+		in:      in,
+		kernel:  kernel,
+		stride:  stride,
+		padding: padding,
+		weights: Parameter{Value: weights, L1: l1, L2: l2},
+	}
+	c.out = Shape{
+		Channels: filters,
+		Height:   slide(in.Height, kernel, stride, padding),
+		Width:    slide(in.Width, kernel, stride, padding),
+	}
+	if biases != nil {
+		c.biases = &Parameter{Value: biases}
+	}
+	return c
+}
+
+// im2col lays out the input under the kernel at each position as a column, with zeros where the kernel is over the
+// padding
+func (c *Conv2D) im2col(inputs mat.Matrix) *mat.Dense {
+	positions := c.out.Height * c.out.Width
+	patches := mat.NewDense(c.in.Channels*c.kernel*c.kernel, positions, nil)
+	c.each(func(row, position, index int) {
+		patches.Set(row, position, inputs.At(index, 0))
+	})
+	return patches
+}
+
+// each calls fn with every row of a patch, position of the kernel and index of the input under it, skipping the
+// padding
+func (c *Conv2D) each(fn func(row, position, index int)) {
+	for oy := 0; oy < c.out.Height; oy++ {
+		for ox := 0; ox < c.out.Width; ox++ {
+			position := oy*c.out.Width + ox
+			for channel := 0; channel < c.in.Channels; channel++ {
+				for ky := 0; ky < c.kernel; ky++ {
+					y := oy*c.stride + ky - c.padding
+					if y < 0 || y >= c.in.Height {
+						continue
+					}
+					for kx := 0; kx < c.kernel; kx++ {
+						x := ox*c.stride + kx - c.padding
+						if x < 0 || x >= c.in.Width {
+							continue
+						}
+						fn((channel*c.kernel+ky)*c.kernel+kx, position, c.in.index(channel, y, x))
+					}
+				}
+			}
+		}
+	}
+}
+
+// col2im adds each patch's errors back to the input under it
+func (c *Conv2D) col2im(patches mat.Matrix) mat.Matrix {
+	errors := mat.NewDense(c.in.Size(), 1, nil)
+	c.each(func(row, position, index int) {
+		errors.Set(index, 0, errors.At(index, 0)+patches.At(row, position))
+	})
+	return errors
+}
+
+// Forward convolves the input with each filter and adds the filter's bias
+func (c *Conv2D) Forward(inputs mat.Matrix, training bool) mat.Matrix {
+	patches := c.im2col(inputs)
+	if training {
+		c.patches = patches
+	}
+	var sums mat.Dense
+	sums.Mul(c.weights.Value, patches)
+	if c.biases != nil {
+		sums.Apply(func(filter, _ int, v float64) float64 {
+			return v + c.biases.Value.At(filter, 0)
+		}, &sums)
+	}
+	// each filter's row of positions is already a channel of the output
+	return mat.NewDense(c.out.Size(), 1, sums.RawMatrix().Data)
+}
+
+// Backward finds the gradients of the filters and biases and passes both errors back under each position
+func (c *Conv2D) Backward(errors Errors) Errors {
+	positions := c.out.Height * c.out.Width
+	gradient := mat.NewDense(c.out.Channels, positions, mat.Col(nil, 0, errors.Gradient))
+	c.weights.Gradient = mat.NewDense(c.out.Channels, c.in.Channels*c.kernel*c.kernel, nil)
+	c.weights.Gradient.Mul(gradient, c.patches.T())
+	if c.biases != nil {
+		c.biases.Gradient = mat.NewDense(c.out.Channels, 1, nil)
+		for filter := 0; filter < c.out.Channels; filter++ {
+			c.biases.Gradient.Set(filter, 0, mat.Sum(gradient.RowView(filter)))
+		}
+	}
+	back := func(m mat.Matrix) mat.Matrix {
+		var patches mat.Dense
+		patches.Mul(c.weights.Value.T(), mat.NewDense(c.out.Channels, positions, mat.Col(nil, 0, m)))
+		return c.col2im(&patches)
+	}
+	return Errors{Error: back(errors.Error), Gradient: back(errors.Gradient)}
+}
+
+// Parameters are the filters and then the biases
+func (c *Conv2D) Parameters() []*Parameter {
+	if c.biases == nil {
+		return []*Parameter{&c.weights}
+	}
+	return []*Parameter{&c.weights, c.biases}
+}
+
+// State is the filters, saved as .wgt, and the biases, saved as .bias
+func (c *Conv2D) State() map[string]*mat.Dense {
+	state := map[string]*mat.Dense{"wgt": c.weights.Value}
+	if c.biases != nil {
+		state["bias"] = c.biases.Value
+	}
+	return state
+}
+
+// SetState restores the filters and biases
+func (c *Conv2D) SetState(state map[string]*mat.Dense) error {
+	weights, ok := state["wgt"]
+	if !ok {
+		return fmt.Errorf("missing weights")
+	}
+	if rows, cols := weights.Dims(); rows != c.out.Channels || cols != c.in.Channels*c.kernel*c.kernel {
+		return fmt.Errorf("expected %dx%d weights, got %dx%d",
+			c.out.Channels, c.in.Channels*c.kernel*c.kernel, rows, cols)
+	}
+	c.weights.Value = weights
+	if biases, ok := state["bias"]; ok && c.biases != nil {
+		c.biases.Value = biases
+	}
+	return nil
+}
+
+// Shape is the shape of the output
+func (c *Conv2D) Shape() Shape {
+	return c.out
+}
+
+func (c *Conv2D) String() string {
+	return fmt.Sprintf("conv %d %dx%d/%d pad %d %s -> %s", c.out.Channels, c.kernel, c.kernel, c.stride, c.padding, c.in, c.out)
+}
+
+// Pooling is a layer that takes the maximum or average of each window of every channel of an image
+type Pooling struct {
+	kind   string
+	in     Shape
+	out    Shape
+	size   int
+	stride int
+	// chosen holds the input each output of a max pool was taken from in the last Forward while training
+	chosen []int
+}
+
+// NewPooling returns a max or average pool of windows size wide and high that move by stride
+func NewPooling(kind string, in Shape, size, stride int) *Pooling {
+	return &Pooling{
+		kind:   kind,
+		in:     in,
+		size:   size,
+		stride: stride,
+		out: Shape{
+			Channels: in.Channels,
+			Height:   slide(in.Height, size, stride, 0),
+			Width:    slide(in.Width, size, stride, 0),
+		},
+	}
+}
+
+// Forward pools each window
+func (p *Pooling) Forward(inputs mat.Matrix, training bool) mat.Matrix {
+	outputs := mat.NewDense(p.out.Size(), 1, nil)
+	var chosen []int
+	if training && p.kind == LayerMaxPool {
+		chosen = make([]int, p.out.Size())
+	}
+	p.each(func(out int, window []int) {
+		if p.kind == LayerAvgPool {
+			var sum float64
+			for _, in := range window {
+				sum += inputs.At(in, 0)
+			}
+			outputs.Set(out, 0, sum/float64(len(window)))
+			return
+		}
+		best := window[0]
+		for _, in := range window[1:] {
+			if inputs.At(in, 0) > inputs.At(best, 0) {
+				best = in
+			}
+		}
+		outputs.Set(out, 0, inputs.At(best, 0))
+		if chosen != nil {
+			chosen[out] = best
+		}
+	})
+	if training {
+		p.chosen = chosen
+	}
+	return outputs
+}
+
+// each calls fn with every output and the inputs in its window
+func (p *Pooling) each(fn func(out int, window []int)) {
+	window := make([]int, 0, p.size*p.size)
+	for channel := 0; channel < p.out.Channels; channel++ {
+		for oy := 0; oy < p.out.Height; oy++ {
+			for ox := 0; ox < p.out.Width; ox++ {
+				window = window[:0]
+				for y := oy * p.stride; y < oy*p.stride+p.size; y++ {
+					for x := ox * p.stride; x < ox*p.stride+p.size; x++ {
+						window = append(window, p.in.index(channel, y, x))
+					}
+				}
+				fn(p.out.index(channel, oy, ox), window)
+			}
+		}
+	}
+}
+
+// Backward passes the errors of a max pool back to the input each output was taken from, and those of an average
+// pool back to every input of the window in equal parts
+func (p *Pooling) Backward(errors Errors) Errors {
+	back := func(m mat.Matrix) mat.Matrix {
+		in := mat.NewDense(p.in.Size(), 1, nil)
+		p.each(func(out int, window []int) {
+			if p.kind == LayerMaxPool {
+				in.Set(p.chosen[out], 0, in.At(p.chosen[out], 0)+m.At(out, 0))
+				return
+			}
+			for _, i := range window {
+				in.Set(i, 0, in.At(i, 0)+m.At(out, 0)/float64(len(window)))
+			}
+		})
+		return in
+	}
+	return Errors{Error: back(errors.Error), Gradient: back(errors.Gradient)}
+}
+
+// Parameters is empty since pooling doesn't learn
+func (p *Pooling) Parameters() []*Parameter {
+	return nil
+}
+
+// State is empty
+func (p *Pooling) State() map[string]*mat.Dense {
+	return nil
+}
+
+// SetState does nothing
+func (p *Pooling) SetState(state map[string]*mat.Dense) error {
+	return nil
+}
+
+// Shape is the shape of the output
+func (p *Pooling) Shape() Shape {
+	return p.out
+}
+
+func (p *Pooling) String() string {
+	return fmt.Sprintf("%s %dx%d/%d %s -> %s", p.kind, p.size, p.size, p.stride, p.in, p.out)
+}
+
+// Flatten is a layer that ends the convolutional layers. Images are already laid out as a column, so it passes its
+// input through unchanged.
+type Flatten struct {
+	in Shape
+}
+
+// NewFlatten returns a flatten layer for an image
+func NewFlatten(in Shape) *Flatten {
+	return &Flatten{in: in}
+}
+
+// Forward passes the input through
+func (f *Flatten) Forward(inputs mat.Matrix, training bool) mat.Matrix {
+	return inputs
+}
+
+// Backward passes the errors through
+func (f *Flatten) Backward(errors Errors) Errors {
+	return errors
+}
+
+// Parameters is empty since flattening doesn't learn
+func (f *Flatten) Parameters() []*Parameter {
+	return nil
+}
+
+// State is empty
+func (f *Flatten) State() map[string]*mat.Dense {
+	return nil
+}
+
+// SetState does nothing
+func (f *Flatten) SetState(state map[string]*mat.Dense) error {
+	return nil
+}
+
+func (f *Flatten) String() string {
+	return fmt.Sprintf("flatten %s -> %d", f.in, f.in.Size())
+}
+
+// ConvSpec describes a convolution, pooling or flatten layer ahead of the dense layers
+type ConvSpec struct {
+	Kind string
+	// Filters is the number of filters of a convolution
+	Filters int
+	// Size is the width and height of a convolution's kernel or a pool's window
+	Size    int
+	Stride  int
+	Padding int
+}
+
+func (s ConvSpec) String() string {
+	switch s.Kind {
+	case LayerConv:
+		spec := fmt.Sprintf("%s:%dx%d", s.Kind, s.Filters, s.Size)
+		if s.Stride != 1 {
+			spec += "/s" + strconv.Itoa(s.Stride)
+		}
+		if s.Padding != 0 {
+			spec += "/p" + strconv.Itoa(s.Padding)
+		}
+		return spec
+	case LayerMaxPool, LayerAvgPool:
+		spec := fmt.Sprintf("%s:%d", s.Kind, s.Size)
+		if s.Stride != s.Size {
+			spec += "/s" + strconv.Itoa(s.Stride)
+		}
+		return spec
+	}
+	return s.Kind
+}
+
+// ParseCNN reads comma separated layers: conv:FILTERSxSIZE with an optional /sSTRIDE (1 by default) and /pPADDING
+// (0 by default), maxpool:SIZE or avgpool:SIZE with an optional /sSTRIDE (the size by default), and flatten, such as
+// conv:8x3/p1,maxpool:2
+func ParseCNN(s string) ([]ConvSpec, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var specs []ConvSpec
+	layers := strings.Split(s, ",")
+	for i, layer := range layers {
+		layer = strings.TrimSpace(layer)
+		splits := strings.Split(layer, ":")
+		spec := ConvSpec{Kind: splits[0]}
+		if spec.Kind == LayerFlatten {
+			if len(splits) != 1 || i != len(layers)-1 {
+				return nil, fmt.Errorf("flatten takes no settings and can only be the last layer, got %s", layer)
+			}
+			specs = append(specs, spec)
+			continue
+		}
+		if len(splits) != 2 {
+			return nil, fmt.Errorf("expected a kind and its settings, such as conv:8x3, got %s", layer)
+		}
+		options := strings.Split(splits[1], "/")
+		var err error
+		switch spec.Kind {
+		case LayerConv:
+			var filters, size int
+			if _, err := fmt.Sscanf(options[0], "%dx%d", &filters, &size); err != nil {
+				return nil, fmt.Errorf("parsing filters and kernel size of %s: %w", layer, err)
+			}
+			spec.Filters, spec.Size, spec.Stride = filters, size, 1
+		case LayerMaxPool, LayerAvgPool:
+			spec.Size, err = strconv.Atoi(options[0])
+			if err != nil {
+				return nil, fmt.Errorf("parsing pool size of %s: %w", layer, err)
+			}
+			spec.Stride = spec.Size
+		default:
+			return nil, fmt.Errorf("invalid layer %s, expected %s, %s, %s or %s",
+				spec.Kind, LayerConv, LayerMaxPool, LayerAvgPool, LayerFlatten)
+		}
+		for _, option := range options[1:] {
+			if len(option) < 2 {
+				return nil, fmt.Errorf("invalid setting %s of %s", option, layer)
+			}
+			value, err := strconv.Atoi(option[1:])
+			if err != nil {
+				return nil, fmt.Errorf("parsing setting %s of %s: %w", option, layer, err)
+			}
+			switch {
+			case option[0] == 's':
+				spec.Stride = value
+			case option[0] == 'p' && spec.Kind == LayerConv:
+				spec.Padding = value
+			default:
+				return nil, fmt.Errorf("invalid setting %s of %s", option, layer)
+			}
+		}
+		if spec.Size < 1 || spec.Stride < 1 || spec.Padding < 0 || spec.Kind == LayerConv && spec.Filters < 1 {
+			return nil, fmt.Errorf("sizes, strides and filters of %s must be positive", layer)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// formatCNN writes layers as ParseCNN reads them
+func formatCNN(specs []ConvSpec) string {
+	formatted := make([]string, len(specs))
+	for i, spec := range specs {
+		formatted[i] = spec.String()
+	}
+	return strings.Join(formatted, ",")
+}
+
+// CheckCNN reports whether convolution and pooling layers fit the images they're given, where there are inputNum
+// inputs of one or more channels of width by height images
+func CheckCNN(specs []ConvSpec, inputNum, width, height int) error {
+	_, err := imageShapes(specs, inputNum, width, height)
+	return err
+}
+
+// imageShapes is the shape of the image going into each layer and of the last one's output
+func imageShapes(specs []ConvSpec, inputNum, width, height int) ([]Shape, error) {
+	if width < 1 || height < 1 || inputNum%(width*height) != 0 {
+		return nil, fmt.Errorf("%d inputs aren't channels of %dx%d images", inputNum, width, height)
+	}
+	shape := Shape{Channels: inputNum / (width * height), Height: height, Width: width}
+	shapes := []Shape{shape}
+	for _, spec := range specs {
+		if spec.Kind == LayerFlatten {
+			break
+		}
+		padding := spec.Padding
+		if spec.Kind != LayerConv {
+			padding = 0
+		}
+		shape.Height = slide(shape.Height, spec.Size, spec.Stride, padding)
+		shape.Width = slide(shape.Width, spec.Size, spec.Stride, padding)
+		if shape.Height < 1 || shape.Width < 1 {
+			return nil, fmt.Errorf("%s is larger than its %s input", spec, shapes[len(shapes)-1])
+		}
+		if spec.Kind == LayerConv {
+			shape.Channels = spec.Filters
+		}
+		shapes = append(shapes, shape)
+	}
+	return shapes, nil
+}
+
+// convolutional composes the layers ahead of the dense layers, each convolution followed by its activation, and
+// returns them with the number of values they pass on. Weights are drawn from rng by init. The layers must have been
+// checked by CheckCNN.
+func (c Config) convolutional(init string, rng *rand.Rand) ([]Layer, int) {
+	if len(c.CNN) == 0 {
+		return nil, c.InputNum
+	}
+	shapes, _ := imageShapes(c.CNN, c.InputNum, c.ImageWidth, c.ImageHeight)
+	var layers []Layer
+	for i, shape := range shapes[:len(shapes)-1] {
+		spec := c.CNN[i]
+		switch spec.Kind {
+		case LayerConv:
+			cols := shape.Channels * spec.Size * spec.Size
+			weights := mat.NewDense(spec.Filters, cols, initialWeights(init, rng, spec.Filters, cols))
+			var biases *mat.Dense
+			if c.Bias {
+				biases = mat.NewDense(spec.Filters, 1, nil)
+			}
+			layers = append(layers,
+				NewConv2D(shape, spec.Size, spec.Stride, spec.Padding, weights, biases, c.L1, c.L2),
+				NewActivation(c.Activator))
+		default:
+			layers = append(layers, NewPooling(spec.Kind, shape, spec.Size, spec.Stride))
+		}
+	}
+	last := shapes[len(shapes)-1]
+	layers = append(layers, NewFlatten(last))
+	return layers, last.Size()
+}
+
+// convCount is the number of convolutions, whose weights are saved ahead of those of the dense layers
+func convCount(specs []ConvSpec) int {
+	var count int
+	for _, spec := range specs {
+		if spec.Kind == LayerConv {
+			count++
+		}
+	}
+	return count
+}
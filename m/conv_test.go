@@ -0,0 +1,120 @@
+package m
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestParseCNN(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected []ConvSpec
+	}{
+		{"", nil},
+		{"conv:8x3", []ConvSpec{{Kind: LayerConv, Filters: 8, Size: 3, Stride: 1}}},
+		{
+			"conv:8x3/p1, maxpool:2,conv:16x5/s2/p2,avgpool:3/s1,flatten",
+			[]ConvSpec{
+				{Kind: LayerConv, Filters: 8, Size: 3, Stride: 1, Padding: 1},
+				{Kind: LayerMaxPool, Size: 2, Stride: 2},
+				{Kind: LayerConv, Filters: 16, Size: 5, Stride: 2, Padding: 2},
+				{Kind: LayerAvgPool, Size: 3, Stride: 1},
+				{Kind: LayerFlatten},
+			},
+		},
+	}
+	for _, tt := range tests {
+		specs, err := ParseCNN(tt.s)
+		if err != nil {
+			t.Errorf("%q: %s", tt.s, err)
+			continue
+		}
+		if !reflect.DeepEqual(specs, tt.expected) {
+			t.Errorf("%q: got %v, expected %v", tt.s, specs, tt.expected)
+		}
+		// the specs are saved as they're formatted
+		if tt.s != "" {
+			if again, err := ParseCNN(formatCNN(specs)); err != nil || !reflect.DeepEqual(again, specs) {
+				t.Errorf("%q: formatted as %q, which parses as %v, %v", tt.s, formatCNN(specs), again, err)
+			}
+		}
+	}
+}
+
+func TestParseCNNErrors(t *testing.T) {
+	for _, s := range []string{
+		"dense:8",
+		"conv",
+		"conv:8",
+		"conv:0x3",
+		"conv:8x0",
+		"conv:8x3/q1",
+		"conv:8x3/s0",
+		"conv:8x3/p-1",
+		"conv:8x3/s",
+		"conv:8x3/sx",
+		"maxpool:x",
+		"maxpool:2/p1",
+		"avgpool:0",
+		"flatten:1",
+		"flatten,conv:8x3",
+	} {
+		if specs, err := ParseCNN(s); err == nil {
+			t.Errorf("%q: expected an error, got %v", s, specs)
+		}
+	}
+}
+
+func TestCheckCNN(t *testing.T) {
+	specs, _ := ParseCNN("conv:4x3,maxpool:2")
+	if err := CheckCNN(specs, 2*8*8, 8, 8); err != nil {
+		t.Errorf("two channels of 8x8 images: %s", err)
+	}
+	if err := CheckCNN(specs, 65, 8, 8); err == nil {
+		t.Error("expected an error for inputs that aren't 8x8 images")
+	}
+	if err := CheckCNN(specs, 9, 3, 3); err == nil {
+		t.Error("expected an error for a pool larger than its input")
+	}
+}
+
+func TestConv2DGradients(t *testing.T) {
+	tests := []struct {
+		name                    string
+		in                      Shape
+		filters, kernel, stride int
+		padding                 int
+		bias                    bool
+	}{
+		{"valid", Shape{Channels: 1, Height: 4, Width: 4}, 2, 3, 1, 0, true},
+		{"padded and strided", Shape{Channels: 2, Height: 5, Width: 4}, 3, 3, 2, 1, true},
+		{"without biases", Shape{Channels: 2, Height: 3, Width: 3}, 1, 2, 1, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(3))
+			weights := randomDense(rng, tt.filters, tt.in.Channels*tt.kernel*tt.kernel)
+			var layer *Conv2D
+			if tt.bias {
+				layer = NewConv2D(tt.in, tt.kernel, tt.stride, tt.padding, weights, randomDense(rng, tt.filters, 1), 0, 0)
+			} else {
+				layer = NewConv2D(tt.in, tt.kernel, tt.stride, tt.padding, weights, nil, 0, 0)
+			}
+			checkGradients(t, layer, randomDense(rng, tt.in.Size(), 1))
+		})
+	}
+}
+
+func TestPoolingGradients(t *testing.T) {
+	in := Shape{Channels: 2, Height: 4, Width: 5}
+	for _, kind := range []string{LayerMaxPool, LayerAvgPool} {
+		for _, stride := range []int{1, 2} {
+			rng := rand.New(rand.NewSource(4))
+			t.Run(fmt.Sprintf("%s stride %d", kind, stride), func(t *testing.T) {
+				checkGradients(t, NewPooling(kind, in, 2, stride), randomDense(rng, in.Size(), 1))
+			})
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package m
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Dataset is a source of lines that can be read from the start once per epoch
+type Dataset interface {
+	// Each calls fn with every line, stopping at the first error
+	Each(fn func(Line) error) error
+}
+
+// Each calls fn with every line in memory
+func (lines Lines) Each(fn func(Line) error) error {
+	for _, line := range lines {
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FileDataset reads its file again every time it's iterated, so the file never has to fit in memory
+type FileDataset struct {
+	Path   string
+	Format ReadOptions
+}
+
+func (d FileDataset) Each(fn func(Line) error) error {
+	switch d.Format.Format {
+	case FormatIDX:
+		return eachIDX(d.Path, d.Format, fn)
+	case FormatLibSVM:
+		return eachLibSVM(d.Path, d.Format, fn)
+	case FormatSequence:
+		return eachSequence(d.Path, d.Format, fn)
+	case "", FormatText:
+		file, err := openData(d.Path)
+		if err != nil {
+			return fmt.Errorf("opening data file: %w", err)
+		}
+		defer file.Close()
+		return eachLine(file, d.Format, fn)
+	}
+	return fmt.Errorf("invalid format %s", d.Format.Format)
+}
+
+// ReadAll loads every line of a dataset into memory
+func ReadAll(dataset Dataset) (Lines, error) {
+	var lines Lines
+	err := dataset.Each(func(line Line) error {
+		lines = append(lines, line)
+		return nil
+	})
+	return lines, err
+}
+
+type shuffled struct {
+	dataset Dataset
+	size    int
+	rng     *rand.Rand
+}
+
+// Shuffled randomizes the order of a dataset each time it's iterated while holding at most size lines in memory.
+// Each line is swapped into a random slot of the buffer and the line it replaces is passed on, so lines can move
+// about size positions from where they started. A buffer as large as the dataset gives a full shuffle. A size of 0 or
+// less leaves the dataset unshuffled.
+func Shuffled(dataset Dataset, size int, rng *rand.Rand) Dataset {
+	if size <= 0 {
+		return dataset
+	}
+	return shuffled{
+		dataset: dataset,
+		size:    size,
+		rng:     rng,
+	}
+}
+
+func (s shuffled) Each(fn func(Line) error) error {
+	buffer := make([]Line, 0, s.size)
+	err := s.dataset.Each(func(line Line) error {
+		if len(buffer) < s.size {
+			buffer = append(buffer, line)
+			return nil
+		}
+		i := s.rng.Intn(len(buffer))
+		next := buffer[i]
+		buffer[i] = line
+		return fn(next)
+	})
+	if err != nil {
+		return err
+	}
+	s.rng.Shuffle(len(buffer), func(i, j int) {
+		buffer[i], buffer[j] = buffer[j], buffer[i]
+	})
+	return Lines(buffer).Each(fn)
+}
@@ -0,0 +1,138 @@
+package m
+
+import (
+	"errors"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// numbered is a dataset of n lines whose only input is their position
+func numbered(n int) Lines {
+	lines := make(Lines, n)
+	for i := range lines {
+		lines[i] = Line{Inputs: []float64{float64(i)}, Targets: []float64{1}}
+	}
+	return lines
+}
+
+// order is the position each line of a numbered dataset was read in
+func order(t *testing.T, dataset Dataset) []int {
+	t.Helper()
+	lines, err := ReadAll(dataset)
+	if err != nil {
+		t.Fatalf("reading: %s", err)
+	}
+	positions := make([]int, len(lines))
+	for i, line := range lines {
+		positions[i] = int(line.Inputs[0])
+	}
+	return positions
+}
+
+func TestFileDataset(t *testing.T) {
+	dir := tempDir(t)
+	text := []byte("0.1 0.2 1 0\n\n0.3 0.4 0 1\n")
+	writeFile(t, filepath.Join(dir, "a.data"), text)
+	writeFile(t, filepath.Join(dir, "a.data.gz"), gzipped(text))
+	expected := Lines{
+		{Inputs: []float64{0.1, 0.2}, Targets: []float64{1, 0}},
+		{Inputs: []float64{0.3, 0.4}, Targets: []float64{0, 1}},
+	}
+	for _, name := range []string{"a.data", "a.data.gz"} {
+		dataset := FileDataset{Path: filepath.Join(dir, name), Format: ReadOptions{InputNum: 2, OutputNum: 2}}
+		// the file is read again every time the dataset is iterated
+		for epoch := 1; epoch <= 2; epoch++ {
+			lines, err := ReadAll(dataset)
+			if err != nil {
+				t.Fatalf("%s epoch %d: %s", name, epoch, err)
+			}
+			if !reflect.DeepEqual(lines, expected) {
+				t.Errorf("%s epoch %d: got %v, expected %v", name, epoch, lines, expected)
+			}
+		}
+	}
+
+	for _, dataset := range []FileDataset{
+		{Path: filepath.Join(dir, "missing.data"), Format: ReadOptions{InputNum: 2, OutputNum: 2}},
+		{Path: filepath.Join(dir, "a.data"), Format: ReadOptions{Format: "parquet", InputNum: 2, OutputNum: 2}},
+	} {
+		if _, err := ReadAll(dataset); err == nil {
+			t.Errorf("%+v: expected an error", dataset)
+		}
+	}
+}
+
+func TestReadAllStopsAtError(t *testing.T) {
+	dir := tempDir(t)
+	writeFile(t, filepath.Join(dir, "a.data"), []byte("0.1 1\n0.2 x\n0.3 1\n"))
+	lines, err := ReadAll(FileDataset{Path: filepath.Join(dir, "a.data"), Format: ReadOptions{InputNum: 1, OutputNum: 1}})
+	var invalid InvalidValueError
+	if !errors.As(err, &invalid) || invalid.Line != 2 {
+		t.Errorf("got %v, expected an InvalidValueError at line 2", err)
+	}
+	if len(lines) != 1 {
+		t.Errorf("got %d lines before the error, expected 1", len(lines))
+	}
+}
+
+func TestShuffled(t *testing.T) {
+	const n = 50
+	lines := numbered(n)
+	full := order(t, Shuffled(lines, n, rand.New(rand.NewSource(1))))
+	again := order(t, Shuffled(lines, n, rand.New(rand.NewSource(1))))
+	if !reflect.DeepEqual(full, again) {
+		t.Error("the same seed shuffled differently")
+	}
+	if reflect.DeepEqual(full, order(t, lines)) {
+		t.Error("a full buffer left the lines in order")
+	}
+
+	for _, size := range []int{1, 5, n, 2 * n} {
+		dataset := Shuffled(lines, size, rand.New(rand.NewSource(2)))
+		first := order(t, dataset)
+		// every line is read once, and no line comes out more than size positions ahead of where it started
+		seen := make(map[int]bool)
+		for position, i := range first {
+			if seen[i] {
+				t.Errorf("size %d: line %d was read twice", size, i)
+			}
+			seen[i] = true
+			if position < i-size {
+				t.Errorf("size %d: line %d was read at %d", size, i, position)
+			}
+		}
+		if len(seen) != n {
+			t.Errorf("size %d: read %d of %d lines", size, len(seen), n)
+		}
+		// each epoch is shuffled again
+		if size > 1 && reflect.DeepEqual(first, order(t, dataset)) {
+			t.Errorf("size %d: two epochs were read in the same order", size)
+		}
+	}
+}
+
+func TestShuffledWithoutBuffer(t *testing.T) {
+	lines := numbered(5)
+	for _, size := range []int{0, -1} {
+		if got := order(t, Shuffled(lines, size, rand.New(rand.NewSource(1)))); !reflect.DeepEqual(got, order(t, lines)) {
+			t.Errorf("size %d: got %v, expected the lines in order", size, got)
+		}
+	}
+}
+
+func TestShuffledStopsAtError(t *testing.T) {
+	stop := errors.New("stop")
+	var count int
+	err := Shuffled(numbered(10), 3, rand.New(rand.NewSource(1))).Each(func(Line) error {
+		count++
+		if count == 4 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || count != 4 {
+		t.Errorf("got %v after %d lines, expected to stop after 4", err, count)
+	}
+}
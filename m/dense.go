@@ -0,0 +1,89 @@
+package m
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Dense is a fully connected layer, whose output is its weights multiplied by its input plus its biases
+type Dense struct {
+	weights Parameter
+	// biases is nil for a layer without biases
+	biases *Parameter
+	inputs mat.Matrix
+}
+
+// NewDense returns a dense layer with a row of weights for each output node and a column for each input. biases
+// may be nil. The weights are regularized by the L1 and L2 coefficients and max-norm, which are off at 0.
+func NewDense(weights, biases *mat.Dense, l1, l2, maxNorm float64) *Dense {
+	d := &Dense{weights: Parameter{Value: weights, L1: l1, L2: l2, MaxNorm: maxNorm}}
+	if biases != nil {
+		d.biases = &Parameter{Value: biases}
+	}
+	return d
+}
+
+// Forward multiplies the input by the weights and adds the biases
+func (d *Dense) Forward(inputs mat.Matrix, training bool) mat.Matrix {
+	if training {
+		d.inputs = inputs
+	}
+	sum := dot(d.weights.Value, inputs)
+	if d.biases != nil {
+		sum = add(sum, d.biases.Value)
+	}
+	return sum
+}
+
+// Backward finds the gradients of the weights and biases and passes both errors back through the weights
+func (d *Dense) Backward(errors Errors) Errors {
+	d.weights.Gradient = dot(errors.Gradient, d.inputs.T()).(*mat.Dense)
+	if d.biases != nil {
+		d.biases.Gradient = mat.DenseCopyOf(errors.Gradient)
+	}
+	return Errors{
+		Error:    dot(d.weights.Value.T(), errors.Error),
+		Gradient: dot(d.weights.Value.T(), errors.Gradient),
+	}
+}
+
+// Parameters are the weights and then the biases
+func (d *Dense) Parameters() []*Parameter {
+	if d.biases == nil {
+		return []*Parameter{&d.weights}
+	}
+	return []*Parameter{&d.weights, d.biases}
+}
+
+// State is the weights, saved as .wgt, and the biases, saved as .bias
+func (d *Dense) State() map[string]*mat.Dense {
+	state := map[string]*mat.Dense{"wgt": d.weights.Value}
+	if d.biases != nil {
+		state["bias"] = d.biases.Value
+	}
+	return state
+}
+
+// SetState restores the weights and biases
+func (d *Dense) SetState(state map[string]*mat.Dense) error {
+	weights, ok := state["wgt"]
+	if !ok {
+		return fmt.Errorf("missing weights")
+	}
+	d.weights.Value = weights
+	if biases, ok := state["bias"]; ok && d.biases != nil {
+		d.biases.Value = biases
+	}
+	return nil
+}
+
+// Size is the number of inputs and outputs
+func (d *Dense) Size() (inputs, outputs int) {
+	outputs, inputs = d.weights.Value.Dims()
+	return inputs, outputs
+}
+
+func (d *Dense) String() string {
+	inputs, outputs := d.Size()
+	return fmt.Sprintf("dense %dx%d", inputs, outputs)
+}
@@ -0,0 +1,102 @@
+package m
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/mat"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// dropoutRate is the fraction of layer i's nodes dropped while training. The output layer is never dropped.
+func (c Config) dropoutRate(i int) float64 {
+	switch {
+	case i >= c.LayerNum-1, len(c.Dropout) == 0:
+		return 0
+	case len(c.Dropout) == 1:
+		// a single rate applies to the hidden layers only, since dropping inputs usually needs a lower rate
+		if i == 0 {
+			return 0
+		}
+		return c.Dropout[0]
+	}
+	return c.Dropout[i]
+}
+
+// Dropout is a layer that zeroes a random fraction of its inputs while training and scales up the rest to keep its
+// expected output the same, so the full network can be used for prediction unchanged
+type Dropout struct {
+	rate float64
+	rng  *rand.Rand
+	mask mat.Matrix
+}
+
+// NewDropout returns a dropout layer that drops a fraction of its inputs, drawn from rng
+func NewDropout(rate float64, rng *rand.Rand) *Dropout {
+	return &Dropout{rate: rate, rng: rng}
+}
+
+// Forward drops inputs while training and passes them through otherwise
+func (d *Dropout) Forward(inputs mat.Matrix, training bool) mat.Matrix {
+	if !training {
+		return inputs
+	}
+	rows, cols := inputs.Dims()
+	mask := mat.NewDense(rows, cols, nil)
+	mask.Apply(func(_, _ int, _ float64) float64 {
+		if d.rng.Float64() < d.rate {
+			return 0
+		}
+		return 1 / (1 - d.rate)
+	}, mask)
+	d.mask = mask
+	return multiply(inputs, mask)
+}
+
+// Backward passes no error back from dropped inputs and scales the rest as their output was
+func (d *Dropout) Backward(errors Errors) Errors {
+	return Errors{Error: multiply(errors.Error, d.mask), Gradient: multiply(errors.Gradient, d.mask)}
+}
+
+// Parameters is empty since dropout doesn't learn
+func (d *Dropout) Parameters() []*Parameter {
+	return nil
+}
+
+// State is empty
+func (d *Dropout) State() map[string]*mat.Dense {
+	return nil
+}
+
+// SetState does nothing
+func (d *Dropout) SetState(state map[string]*mat.Dense) error {
+	return nil
+}
+
+func (d *Dropout) String() string {
+	return fmt.Sprintf("dropout %g", d.rate)
+}
+
+// ParseDropout reads a single dropout rate for every hidden layer, or a comma separated rate for the input and each
+// hidden layer
+func ParseDropout(s string, layerNum int) ([]float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	splits := strings.Split(s, ",")
+	if len(splits) != 1 && len(splits) != layerNum-1 {
+		return nil, fmt.Errorf("expected 1 rate or %d for the input and hidden layers, got %d", layerNum-1, len(splits))
+	}
+	rates := make([]float64, len(splits))
+	for i, split := range splits {
+		rate, err := strconv.ParseFloat(strings.TrimSpace(split), 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing dropout rate %d: %w", i+1, err)
+		}
+		if rate < 0 || rate >= 1 {
+			return nil, fmt.Errorf("dropout rate %g must be at least 0 and less than 1", rate)
+		}
+		rates[i] = rate
+	}
+	return rates, nil
+}
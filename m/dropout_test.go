@@ -0,0 +1,141 @@
+package m
+
+import (
+	"gonum.org/v1/gonum/mat"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDropout(t *testing.T) {
+	const rows, cols, rate = 100, 50, 0.3
+	inputs := mat.NewDense(rows, cols, nil)
+	inputs.Apply(func(i, j int, _ float64) float64 {
+		return float64(i*cols+j+1) / (rows * cols)
+	}, inputs)
+
+	d := NewDropout(rate, rand.New(rand.NewSource(1)))
+	if outputs := d.Forward(inputs, false); outputs != mat.Matrix(inputs) {
+		t.Error("dropout changed the inputs outside of training")
+	}
+
+	outputs := d.Forward(inputs, true)
+	var dropped int
+	var inputSum, outputSum float64
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			in, out := inputs.At(i, j), outputs.At(i, j)
+			inputSum += in
+			outputSum += out
+			switch {
+			case out == 0:
+				dropped++
+			case math.Abs(out-in/(1-rate)) > 1e-12:
+				t.Fatalf("kept input %g became %g, expected it scaled by 1/(1-%g)", in, out, rate)
+			}
+		}
+	}
+	// inverted dropout keeps the expected output the same, so the network predicts without any scaling
+	if fraction := float64(dropped) / (rows * cols); math.Abs(fraction-rate) > 0.02 {
+		t.Errorf("dropped %g of the inputs, expected about %g", fraction, rate)
+	}
+	if math.Abs(outputSum/inputSum-1) > 0.03 {
+		t.Errorf("outputs sum to %g of the inputs, expected about the same", outputSum/inputSum)
+	}
+
+	// errors flow back only through the inputs that were kept, scaled as they were
+	ones := mat.NewDense(rows, cols, nil)
+	ones.Apply(func(_, _ int, _ float64) float64 { return 1 }, ones)
+	errs := d.Backward(Errors{Error: ones, Gradient: ones})
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			expected := outputs.At(i, j) / inputs.At(i, j)
+			if math.Abs(errs.Error.At(i, j)-expected) > 1e-12 || math.Abs(errs.Gradient.At(i, j)-expected) > 1e-12 {
+				t.Fatalf("error at %d,%d got %g and %g, expected %g", i, j, errs.Error.At(i, j),
+					errs.Gradient.At(i, j), expected)
+			}
+		}
+	}
+}
+
+func TestDropoutSeeded(t *testing.T) {
+	inputs := mat.NewDense(4, 8, nil)
+	inputs.Apply(func(_, _ int, _ float64) float64 { return 1 }, inputs)
+	mask := func(seed int64) mat.Matrix {
+		return NewDropout(0.5, rand.New(rand.NewSource(seed))).Forward(inputs, true)
+	}
+	if !mat.Equal(mask(1), mask(1)) {
+		t.Error("the same seed dropped different inputs")
+	}
+	if mat.Equal(mask(1), mask(2)) {
+		t.Error("different seeds dropped the same inputs")
+	}
+}
+
+func TestDropoutRate(t *testing.T) {
+	tests := []struct {
+		name     string
+		dropout  []float64
+		expected []float64
+	}{
+		{"none", nil, []float64{0, 0, 0, 0}},
+		{"hidden only", []float64{0.5}, []float64{0, 0.5, 0.5, 0}},
+		{"per layer", []float64{0.2, 0.5, 0.4}, []float64{0.2, 0.5, 0.4, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Config{LayerNum: 4, Dropout: tt.dropout}
+			for i, expected := range tt.expected {
+				if got := c.dropoutRate(i); got != expected {
+					t.Errorf("layer %d got %g, expected %g", i, got, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestDropoutLayers(t *testing.T) {
+	c := Config{InputNum: 4, HiddenNum: 3, OutputNum: 2, LayerNum: 3, Activator: Sigmoid{}, Dropout: []float64{0.2, 0.5}}
+	count := func(s *Sequential) int {
+		var dropouts int
+		for _, layer := range s.Layers {
+			if _, ok := layer.(*Dropout); ok {
+				dropouts++
+			}
+		}
+		return dropouts
+	}
+	if dropouts := count(c.sequential(nil, rand.New(rand.NewSource(1)))); dropouts != 2 {
+		t.Errorf("training layers have %d dropouts, expected 2", dropouts)
+	}
+	// a network loaded for prediction has no dropout source and no dropout layers
+	if dropouts := count(c.sequential(nil, nil)); dropouts != 0 {
+		t.Errorf("prediction layers have %d dropouts, expected none", dropouts)
+	}
+}
+
+func TestParseDropout(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected []float64
+		err      bool
+	}{
+		{"", nil, false},
+		{"0.5", []float64{0.5}, false},
+		{"0.2, 0.5,0", []float64{0.2, 0.5, 0}, false},
+		{"0.2,0.5", nil, true},
+		{"0.2,x,0.5", nil, true},
+		{"1", nil, true},
+		{"-0.1", nil, true},
+	}
+	for _, tt := range tests {
+		rates, err := ParseDropout(tt.s, 4)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: got error %v, expected an error: %t", tt.s, err, tt.err)
+			continue
+		}
+		if !closeValues(rates, tt.expected) {
+			t.Errorf("%q: got %v, expected %v", tt.s, rates, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package m
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Combinations of the outputs of an ensemble's members
+const (
+	// CombineAverage averages the members' probabilities, their outputs in multi-label mode or their values in
+	// regression and autoencoder mode
+	CombineAverage = "average"
+	// CombineVote gives every member one vote for the label it predicts, or for each label it predicts in multi-label
+	// mode
+	CombineVote = "vote"
+	// CombineWeighted weighs each member's vote, or its values in regression and autoencoder mode, by the accuracy or
+	// R² its run was tested with
+	CombineWeighted = "weighted"
+)
+
+// CheckCombination returns an error if the combination is not average, vote or weighted
+func CheckCombination(combination string) error {
+	switch combination {
+	case CombineAverage, CombineVote, CombineWeighted:
+		return nil
+	}
+	return fmt.Errorf("invalid combination %s, expected %s, %s or %s", combination, CombineAverage, CombineVote,
+		CombineWeighted)
+}
+
+// splitEnsembleRef splits a reference to an ensemble of the form name:members or name:members:combination. ok is
+// false for a reference to a single run.
+func splitEnsembleRef(ref string) (name, members, combination string, ok bool) {
+	splits := strings.SplitN(ref, ":", 3)
+	if len(splits) == 1 {
+		return ref, "", "", false
+	}
+	combination = CombineAverage
+	if len(splits) == 3 {
+		combination = splits[2]
+	}
+	return splits[0], splits[1], combination, true
+}
+
+// ensembleRuns finds the runs of the named dataset that make up an ensemble. Members of the form topN are up to N of
+// its most accurate tested runs, otherwise they are a + separated list of run ending times and @tags.
+func ensembleRuns(name, members string) ([]runInfo, error) {
+	if strings.HasPrefix(members, "top") {
+		n, err := strconv.Atoi(strings.TrimPrefix(members, "top"))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid ensemble members %s, expected top followed by a positive number", members)
+		}
+		return topRuns(name, n)
+	}
+	var runs []runInfo
+	for _, member := range strings.Split(members, "+") {
+		var run runInfo
+		var err error
+		if strings.HasPrefix(member, "@") {
+			run, err = resolveRun(name + member)
+		} else {
+			run, err = runFor(name, member)
+		}
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// topRuns returns up to n of the most accurate tested runs of the named dataset, from the most accurate down. Only
+// runs in the dataset's default mode are included, so a classifier and an autoencoder trained under the same name
+// aren't ranked against each other or combined.
+func topRuns(name string, n int) ([]runInfo, error) {
+	runs, err := defaultModeRuns(name)
+	if err != nil {
+		return nil, err
+	}
+	var tested []runInfo
+	for _, run := range runs {
+		if run.tested {
+			tested = append(tested, run)
+		}
+	}
+	if len(tested) == 0 {
+		return nil, fmt.Errorf("no tested %s runs of %s in %s", runs[0].mode, name, analysisFilepath)
+	}
+	sort.SliceStable(tested, func(i, j int) bool {
+		return tested[i].score > tested[j].score
+	})
+	top := make([]runInfo, 0, n)
+	for _, run := range tested {
+		if len(top) == n {
+			break
+		}
+		top = append(top, run)
+	}
+	return top, nil
+}
+
+// ensembleRun joins the ending times of an ensemble's runs into the run it is identified by
+func ensembleRun(runs []runInfo) string {
+	endTimes := make([]string, len(runs))
+	for i, run := range runs {
+		endTimes[i] = run.endTime
+	}
+	return strings.Join(endTimes, "+")
+}
+
+// newEnsemble combines the models loaded from runs into a single model. The members must have been trained for the
+// same mode, inputs and labels. Everything besides the outputs, such as the activator and transform, is taken from
+// the first member.
+func newEnsemble(runs []runInfo, members []*Model, combination string) (*Model, error) {
+	first := members[0]
+	for _, member := range members[1:] {
+		switch {
+		case member.mode != first.mode:
+			return nil, fmt.Errorf("run %s is %s, unlike run %s, which is %s", member.run, member.mode, first.run,
+				first.mode)
+		case member.inputNum != first.inputNum || member.Sequence() != first.Sequence():
+			return nil, fmt.Errorf("run %s has %d inputs, unlike run %s, which has %d", member.run, member.inputNum,
+				first.run, first.inputNum)
+		case strings.Join(member.labels, ",") != strings.Join(first.labels, ","):
+			return nil, fmt.Errorf("run %s has the labels %s, unlike run %s, which has %s", member.run,
+				strings.Join(member.labels, ","), first.run, strings.Join(first.labels, ","))
+		}
+	}
+	seen := make(map[string]bool, len(runs))
+	for _, run := range runs {
+		if seen[run.endTime] {
+			return nil, fmt.Errorf("run %s is included more than once", run.endTime)
+		}
+		seen[run.endTime] = true
+	}
+	if combination == CombineVote && (first.mode == ModeRegression || first.mode == ModeAutoencoder) {
+		return nil, fmt.Errorf("the values of %s runs can't be voted on, use %s or %s", first.mode, CombineAverage,
+			CombineWeighted)
+	}
+	weights := make([]float64, len(runs))
+	for i, run := range runs {
+		weights[i] = 1
+		if combination != CombineWeighted {
+			continue
+		}
+		if !run.tested || run.score <= 0 {
+			return nil, fmt.Errorf("run %s has no positive test score to weigh it by", run.endTime)
+		}
+		weights[i] = run.score
+	}
+
+	ensemble := *first
+	ensemble.run = ensembleRun(runs)
+	ensemble.sequential = nil
+	ensemble.encoder = 0
+	ensemble.members = members
+	ensemble.weights = weights
+	ensemble.combination = combination
+	if first.mode == ModeMultiLabel && combination != CombineAverage {
+		// a label is predicted by a majority of the (weighted) votes
+		ensemble.thresholds = make([]float64, len(first.thresholds))
+		for i := range ensemble.thresholds {
+			ensemble.thresholds[i] = 0.5
+		}
+	}
+	return &ensemble, nil
+}
+
+// combine returns the weighted mean of the members' outputs, probabilities or votes for an input. Classifier votes
+// go to the label with the highest output, and the votes for each label are the fraction of the members, or of their
+// weights, that predicted it.
+func (model *Model) combine(inputData []float64) []float64 {
+	combined := make([]float64, model.OutputNum())
+	var total float64
+	for i, member := range model.members {
+		weight := model.weights[i]
+		total += weight
+		outputs := member.outputs(inputData)
+		switch {
+		case model.mode == ModeRegression || model.mode == ModeAutoencoder:
+			addWeighted(combined, outputs, weight)
+		case model.mode == ModeMultiLabel && model.combination == CombineAverage:
+			addWeighted(combined, outputs, weight)
+		case model.mode == ModeMultiLabel:
+			for j, o := range outputs {
+				if o > member.thresholds[j] {
+					combined[j] += weight
+				}
+			}
+		case model.combination == CombineAverage:
+			if calibrator, ok := member.outputActivation().Activator().(Calibrator); ok {
+				outputs = calibrator.Probabilities(outputs)
+			}
+			addWeighted(combined, outputs, weight)
+		default:
+			combined[maxIndex(outputs)] += weight
+		}
+	}
+	for i := range combined {
+		combined[i] /= total
+	}
+	return combined
+}
+
+// addWeighted adds weighted values to sums, in place
+func addWeighted(sums, values []float64, weight float64) {
+	for i, v := range values {
+		sums[i] += weight * v
+	}
+}
+
+// maxIndex is the index of the highest value, or the first of the highest when they tie
+func maxIndex(values []float64) int {
+	max := 0
+	for i, v := range values {
+		if v > values[max] {
+			max = i
+		}
+	}
+	return max
+}
+
+// Members are the models an ensemble combines, or nil for a model of a single run
+func (model *Model) Members() []*Model {
+	return model.members
+}
+
+// Combination is how an ensemble combines the outputs of its members, or empty for a model of a single run
+func (model *Model) Combination() string {
+	return model.combination
+}
@@ -2,6 +2,7 @@ package m
 
 import (
 	"bufio"
+	"encoding/csv"
 	"fmt"
 	"io"
 	"strconv"
@@ -14,53 +15,266 @@ type Line struct {
 }
 type Lines []Line
 
+// Delimiters understood by ReadOptions
+const (
+	DelimiterSpace = "space"
+	DelimiterCSV   = "csv"
+	DelimiterTSV   = "tsv"
+)
+
+// ReadOptions describes the layout of a data file. The zero value with InputNum and OutputNum set reads the
+// original format: whitespace separated rows of inputs followed by targets without a header.
+type ReadOptions struct {
+	// Format is text (the default), idx or libsvm. The remaining text options only apply to the text format.
+	Format string
+	// LabelsPath is the label file paired with an IDX image file. By default it's found by the MNIST naming
+	// convention.
+	LabelsPath string
+	// Labels are the target labels that LIBSVM labels are matched against
+	Labels []string
+	// Delimiter is space (any run of spaces or tabs), csv or tsv. It defaults to space.
+	Delimiter string
+	// Header means the first row names the columns
+	Header bool
+	// Comment starts a line that is ignored, such as #. Blank lines are always ignored.
+	Comment string
+	// InputColumns and TargetColumns pick columns by header name. When only targets are named, every other column
+	// is an input. When neither is named, the first InputNum columns are inputs and the next OutputNum are targets.
+	InputColumns  []string
+	TargetColumns []string
+	InputNum      int
+	OutputNum     int
+}
+
+// GetLines reads whitespace separated rows of inputs followed by targets
 func GetLines(reader io.Reader, inputNum, outputNum int) (Lines, error) {
-	scanner := bufio.NewScanner(reader)
+	return ReadLines(reader, ReadOptions{
+		InputNum:  inputNum,
+		OutputNum: outputNum,
+	})
+}
+
+// ReadLines reads every line of a data file laid out as described by the options
+func ReadLines(reader io.Reader, opts ReadOptions) (Lines, error) {
 	var lines Lines
+	err := eachLine(reader, opts, func(line Line) error {
+		lines = append(lines, line)
+		return nil
+	})
+	return lines, err
+}
+
+// eachLine parses every line of a data file and passes it to fn, stopping at the first error
+func eachLine(reader io.Reader, opts ReadOptions, fn func(Line) error) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var columns *columnLayout
 	var lineNum int
 	for scanner.Scan() {
 		lineNum++
-		splits := strings.Split(scanner.Text(), " ")
-		if len(splits) != inputNum+outputNum {
-			return lines, errInvalidLine{
-				lineNum:  lineNum,
-				splits:   len(splits),
-				expected: inputNum + outputNum,
+		text := scanner.Text()
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || (opts.Comment != "" && strings.HasPrefix(trimmed, opts.Comment)) {
+			continue
+		}
+		splits, err := opts.split(text)
+		if err != nil {
+			return fmt.Errorf("at line %d, splitting values: %w", lineNum, err)
+		}
+		if columns == nil {
+			var header []string
+			if opts.Header {
+				header = splits
 			}
+			columns, err = opts.layout(header, len(splits))
+			if err != nil {
+				return err
+			}
+			if opts.Header {
+				continue
+			}
+		}
+		line, err := columns.parse(lineNum, splits)
+		if err != nil {
+			return err
+		}
+		err = fn(line)
+		if err != nil {
+			return err
 		}
-		inputs := make([]float64, inputNum)
-		targets := make([]float64, outputNum)
-		for i, split := range splits {
-			if i < inputNum {
-				num, err := strconv.ParseFloat(split, 64)
-				if err != nil {
-					return lines, fmt.Errorf("parsing input: %w", err)
-				}
-				inputs[i] = num
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading lines: %w", err)
+	}
+	return nil
+}
+
+func (opts ReadOptions) split(text string) ([]string, error) {
+	switch opts.Delimiter {
+	case "", DelimiterSpace:
+		return strings.Fields(text), nil
+	case DelimiterCSV, DelimiterTSV:
+		r := csv.NewReader(strings.NewReader(text))
+		if opts.Delimiter == DelimiterTSV {
+			r.Comma = '\t'
+		}
+		r.TrimLeadingSpace = true
+		splits, err := r.Read()
+		if err != nil {
+			return nil, err
+		}
+		for i := range splits {
+			splits[i] = strings.TrimSpace(splits[i])
+		}
+		return splits, nil
+	}
+	return nil, fmt.Errorf("invalid delimiter %s", opts.Delimiter)
+}
+
+// columnLayout maps the values of a row to inputs and targets
+type columnLayout struct {
+	names   []string
+	width   int
+	inputs  []int
+	targets []int
+}
+
+// layout works out which columns are inputs and targets from the header, or from the sizes when there is no header
+func (opts ReadOptions) layout(header []string, width int) (*columnLayout, error) {
+	columns := &columnLayout{
+		names: header,
+		width: width,
+	}
+	if len(opts.InputColumns) == 0 && len(opts.TargetColumns) == 0 {
+		columns.width = opts.InputNum + opts.OutputNum
+		for i := 0; i < columns.width; i++ {
+			if i < opts.InputNum {
+				columns.inputs = append(columns.inputs, i)
 			} else {
-				num, err := strconv.ParseFloat(split, 64)
-				if err != nil {
-					return lines, fmt.Errorf("parsing target: %w", err)
-				}
-				targets[i-inputNum] = num
+				columns.targets = append(columns.targets, i)
+			}
+		}
+		return columns, nil
+	}
+	if header == nil {
+		return nil, fmt.Errorf("input and target columns can only be named when there is a header")
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[name] = i
+	}
+	var err error
+	columns.targets, err = indexesOf(index, opts.TargetColumns)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.InputColumns) > 0 {
+		columns.inputs, err = indexesOf(index, opts.InputColumns)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		isTarget := make(map[int]bool, len(columns.targets))
+		for _, i := range columns.targets {
+			isTarget[i] = true
+		}
+		for i := range header {
+			if !isTarget[i] {
+				columns.inputs = append(columns.inputs, i)
 			}
 		}
-		line := Line{
-			Inputs:  inputs,
-			Targets: targets,
+	}
+	if opts.InputNum != 0 && len(columns.inputs) != opts.InputNum {
+		return nil, fmt.Errorf("expected %d input columns, got %d", opts.InputNum, len(columns.inputs))
+	}
+	if opts.OutputNum != 0 && len(columns.targets) != opts.OutputNum {
+		return nil, fmt.Errorf("expected %d target columns, got %d", opts.OutputNum, len(columns.targets))
+	}
+	return columns, nil
+}
+
+func indexesOf(index map[string]int, names []string) ([]int, error) {
+	indexes := make([]int, len(names))
+	for i, name := range names {
+		var ok bool
+		indexes[i], ok = index[name]
+		if !ok {
+			return nil, UnknownColumnError{Column: name}
 		}
-		lines = append(lines, line)
 	}
-	return lines, nil
+	return indexes, nil
 }
 
-type errInvalidLine struct {
-	lineNum  int
-	splits   int
-	expected int
+func (columns *columnLayout) name(i int) string {
+	if columns.names != nil {
+		return columns.names[i]
+	}
+	return strconv.Itoa(i + 1)
 }
 
-func (e errInvalidLine) Error() string {
+func (columns *columnLayout) parse(lineNum int, splits []string) (Line, error) {
+	if len(splits) != columns.width {
+		return Line{}, InvalidLineError{
+			Line:     lineNum,
+			Values:   len(splits),
+			Expected: columns.width,
+		}
+	}
+	line := Line{
+		Inputs:  make([]float64, len(columns.inputs)),
+		Targets: make([]float64, len(columns.targets)),
+	}
+	for i, column := range columns.inputs {
+		num, err := strconv.ParseFloat(splits[column], 64)
+		if err != nil {
+			return Line{}, InvalidValueError{Line: lineNum, Column: columns.name(column), Value: splits[column], Err: err}
+		}
+		line.Inputs[i] = num
+	}
+	for i, column := range columns.targets {
+		num, err := strconv.ParseFloat(splits[column], 64)
+		if err != nil {
+			return Line{}, InvalidValueError{Line: lineNum, Column: columns.name(column), Value: splits[column], Err: err}
+		}
+		line.Targets[i] = num
+	}
+	return line, nil
+}
+
+// InvalidLineError is returned when a line doesn't have the expected number of values
+type InvalidLineError struct {
+	Line     int
+	Values   int
+	Expected int
+}
+
+func (e InvalidLineError) Error() string {
 	return fmt.Sprintf("at line %d, expected %d values, got %d",
-		e.lineNum, e.expected, e.splits)
+		e.Line, e.Expected, e.Values)
+}
+
+// InvalidValueError is returned when a value can't be parsed. Column is the header name of the column, or its
+// position counting from 1 when there is no header.
+type InvalidValueError struct {
+	Line   int
+	Column string
+	Value  string
+	Err    error
+}
+
+func (e InvalidValueError) Error() string {
+	return fmt.Sprintf("at line %d, column %s: invalid value %q: %s", e.Line, e.Column, e.Value, e.Err.Error())
+}
+
+func (e InvalidValueError) Unwrap() error {
+	return e.Err
+}
+
+// UnknownColumnError is returned when a named input or target column isn't in the header
+type UnknownColumnError struct {
+	Column string
+}
+
+func (e UnknownColumnError) Error() string {
+	return fmt.Sprintf("column %s is not in the header", e.Column)
 }
@@ -0,0 +1,173 @@
+package m
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReadLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		opts     ReadOptions
+		expected Lines
+	}{
+		{
+			name:     "space",
+			data:     "0.1  0.2\t1\n\n0.3 0.4 0\n",
+			opts:     ReadOptions{InputNum: 2, OutputNum: 1},
+			expected: Lines{{Inputs: []float64{0.1, 0.2}, Targets: []float64{1}}, {Inputs: []float64{0.3, 0.4}, Targets: []float64{0}}},
+		},
+		{
+			name:     "csv",
+			data:     "0.1, 0.2,1\n\"0.3\",0.4,0\n",
+			opts:     ReadOptions{Delimiter: DelimiterCSV, InputNum: 2, OutputNum: 1},
+			expected: Lines{{Inputs: []float64{0.1, 0.2}, Targets: []float64{1}}, {Inputs: []float64{0.3, 0.4}, Targets: []float64{0}}},
+		},
+		{
+			name:     "tsv with header and comments",
+			data:     "# measurements\na\tb\ty\n# first\n0.1\t0.2\t1\n",
+			opts:     ReadOptions{Delimiter: DelimiterTSV, Header: true, Comment: "#", InputNum: 2, OutputNum: 1},
+			expected: Lines{{Inputs: []float64{0.1, 0.2}, Targets: []float64{1}}},
+		},
+		{
+			name:     "target columns",
+			data:     "y,a,b\n1,0.1,0.2\n",
+			opts:     ReadOptions{Delimiter: DelimiterCSV, Header: true, TargetColumns: []string{"y"}},
+			expected: Lines{{Inputs: []float64{0.1, 0.2}, Targets: []float64{1}}},
+		},
+		{
+			name: "input and target columns",
+			data: "id,b,a,y,z\n7,0.2,0.1,1,0\n",
+			opts: ReadOptions{Delimiter: DelimiterCSV, Header: true, InputColumns: []string{"a", "b"},
+				TargetColumns: []string{"z", "y"}, InputNum: 2, OutputNum: 2},
+			expected: Lines{{Inputs: []float64{0.1, 0.2}, Targets: []float64{0, 1}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := ReadLines(strings.NewReader(tt.data), tt.opts)
+			if err != nil {
+				t.Fatalf("reading: %s", err)
+			}
+			if !reflect.DeepEqual(lines, tt.expected) {
+				t.Errorf("got %v, expected %v", lines, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReadLinesLayoutErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		opts ReadOptions
+	}{
+		{"named without header", "1,2,3\n", ReadOptions{Delimiter: DelimiterCSV, TargetColumns: []string{"y"}}},
+		{"wrong input count", "a,b,y\n1,2,3\n", ReadOptions{Delimiter: DelimiterCSV, Header: true,
+			TargetColumns: []string{"y"}, InputNum: 3}},
+		{"wrong target count", "a,b,y\n1,2,3\n", ReadOptions{Delimiter: DelimiterCSV, Header: true,
+			TargetColumns: []string{"y"}, OutputNum: 2}},
+		{"invalid delimiter", "1 2 3\n", ReadOptions{Delimiter: "pipe", InputNum: 2, OutputNum: 1}},
+		{"bad quoting", "1,\"2,3\n", ReadOptions{Delimiter: DelimiterCSV, InputNum: 2, OutputNum: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadLines(strings.NewReader(tt.data), tt.opts); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestUnknownColumnError(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   ReadOptions
+		column string
+	}{
+		{"target", ReadOptions{TargetColumns: []string{"label"}}, "label"},
+		{"input", ReadOptions{InputColumns: []string{"a", "c"}, TargetColumns: []string{"y"}}, "c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Delimiter = DelimiterCSV
+			tt.opts.Header = true
+			_, err := ReadLines(strings.NewReader("a,b,y\n1,2,3\n"), tt.opts)
+			var unknown UnknownColumnError
+			if !errors.As(err, &unknown) {
+				t.Fatalf("got %v, expected an UnknownColumnError", err)
+			}
+			if unknown.Column != tt.column {
+				t.Errorf("got column %s, expected %s", unknown.Column, tt.column)
+			}
+		})
+	}
+}
+
+func TestInvalidValueError(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		opts   ReadOptions
+		line   int
+		column string
+		value  string
+	}{
+		{"input by position", "1 2 3\n\n4 x 6\n", ReadOptions{InputNum: 2, OutputNum: 1}, 3, "2", "x"},
+		{"target by position", "1 2 ?\n", ReadOptions{InputNum: 2, OutputNum: 1}, 1, "3", "?"},
+		{"by header name", "a,b,y\n1,2,3\n1,,3\n", ReadOptions{Delimiter: DelimiterCSV, Header: true,
+			TargetColumns: []string{"y"}}, 3, "b", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadLines(strings.NewReader(tt.data), tt.opts)
+			var invalid InvalidValueError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("got %v, expected an InvalidValueError", err)
+			}
+			if invalid.Line != tt.line || invalid.Column != tt.column || invalid.Value != tt.value {
+				t.Errorf("got line %d, column %s, value %q, expected line %d, column %s, value %q",
+					invalid.Line, invalid.Column, invalid.Value, tt.line, tt.column, tt.value)
+			}
+			if !errors.Is(err, strconv.ErrSyntax) {
+				t.Errorf("%v doesn't unwrap to strconv.ErrSyntax", err)
+			}
+			var numErr *strconv.NumError
+			if !errors.As(err, &numErr) || numErr.Num != tt.value {
+				t.Errorf("%v doesn't unwrap to the strconv.NumError for %q", err, tt.value)
+			}
+		})
+	}
+}
+
+func TestInvalidLineError(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		opts     ReadOptions
+		line     int
+		values   int
+		expected int
+	}{
+		{"short", "1 2 3\n# skipped\n1 2\n", ReadOptions{Comment: "#", InputNum: 2, OutputNum: 1}, 3, 2, 3},
+		{"long", "1 2 3 4\n", ReadOptions{InputNum: 2, OutputNum: 1}, 1, 4, 3},
+		{"against header", "a,b,y\n1,2,3\n1,2\n", ReadOptions{Delimiter: DelimiterCSV, Header: true,
+			TargetColumns: []string{"y"}}, 3, 2, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadLines(strings.NewReader(tt.data), tt.opts)
+			var invalid InvalidLineError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("got %v, expected an InvalidLineError", err)
+			}
+			if invalid.Line != tt.line || invalid.Values != tt.values || invalid.Expected != tt.expected {
+				t.Errorf("got %+v, expected line %d with %d of %d values", invalid, tt.line, tt.values, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package m
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Formats of data files understood by ReadOptions
+const (
+	// FormatText is delimited rows of inputs and targets, described by the rest of the ReadOptions
+	FormatText = "text"
+	// FormatIDX is the binary format of MNIST and Fashion-MNIST, an image file paired with a label file
+	FormatIDX = "idx"
+	// FormatLibSVM is sparse text rows of a label followed by index:value pairs counting from 1
+	FormatLibSVM = "libsvm"
+	// FormatSequence is text rows of one sequence each, whose steps of InputNum values and then targets are
+	// separated by semicolons
+	FormatSequence = "sequence"
+)
+
+// openData opens a data file, decompressing it if it ends with .gz
+func openData(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return file, nil
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("decompressing %s: %w", path, err)
+	}
+	return gzipFile{gz, file}, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+// IDXLabelsPath finds the label file for an IDX image file by the MNIST naming convention, such as
+// train-labels-idx1-ubyte for train-images-idx3-ubyte
+func IDXLabelsPath(imagesPath string) string {
+	dir, base := filepath.Split(imagesPath)
+	return filepath.Join(dir, strings.Replace(base, "images-idx3", "labels-idx1", 1))
+}
+
+const idxUnsignedByte = 0x08
+
+// readIDXHeader reads the magic number and dimensions at the start of an IDX file
+func readIDXHeader(r io.Reader) ([]int, error) {
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, fmt.Errorf("reading magic number: %w", err)
+	}
+	if magic[0] != 0 || magic[1] != 0 {
+		return nil, fmt.Errorf("not an IDX file")
+	}
+	if magic[2] != idxUnsignedByte {
+		return nil, fmt.Errorf("unsupported IDX data type 0x%02x, only unsigned bytes are supported", magic[2])
+	}
+	dims := make([]int, magic[3])
+	for i := range dims {
+		var dim uint32
+		if err := binary.Read(r, binary.BigEndian, &dim); err != nil {
+			return nil, fmt.Errorf("reading dimension %d: %w", i, err)
+		}
+		dims[i] = int(dim)
+	}
+	return dims, nil
+}
+
+// eachIDX reads an IDX image file and its label file. Pixels are scaled from 0..255 into 0..1 and each label
+// becomes a one-hot target. With no outputs, as when an autoencoder reads images, the labels are left out.
+func eachIDX(imagesPath string, opts ReadOptions, fn func(Line) error) error {
+	labelsPath := opts.LabelsPath
+	if labelsPath == "" {
+		labelsPath = IDXLabelsPath(imagesPath)
+	}
+	images, err := openData(imagesPath)
+	if err != nil {
+		return fmt.Errorf("opening images: %w", err)
+	}
+	defer images.Close()
+	labels, err := openData(labelsPath)
+	if err != nil {
+		return fmt.Errorf("opening labels: %w", err)
+	}
+	defer labels.Close()
+	imageReader := bufio.NewReader(images)
+	labelReader := bufio.NewReader(labels)
+
+	imageDims, err := readIDXHeader(imageReader)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", imagesPath, err)
+	}
+	labelDims, err := readIDXHeader(labelReader)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", labelsPath, err)
+	}
+	if len(imageDims) < 2 || len(labelDims) != 1 {
+		return fmt.Errorf("expected images with at least 2 dimensions and labels with 1, got %d and %d",
+			len(imageDims), len(labelDims))
+	}
+	if imageDims[0] != labelDims[0] {
+		return fmt.Errorf("%d images but %d labels", imageDims[0], labelDims[0])
+	}
+	size := 1
+	for _, dim := range imageDims[1:] {
+		size *= dim
+	}
+	if opts.InputNum != 0 && size != opts.InputNum {
+		return fmt.Errorf("images have %d pixels, expected %d inputs", size, opts.InputNum)
+	}
+
+	pixels := make([]byte, size)
+	for i := 0; i < imageDims[0]; i++ {
+		if _, err := io.ReadFull(imageReader, pixels); err != nil {
+			return fmt.Errorf("reading image %d: %w", i, err)
+		}
+		label, err := labelReader.ReadByte()
+		if err != nil {
+			return fmt.Errorf("reading label %d: %w", i, err)
+		}
+		if opts.OutputNum != 0 && int(label) >= opts.OutputNum {
+			return fmt.Errorf("label %d of image %d is outside the %d outputs", label, i, opts.OutputNum)
+		}
+		line := Line{
+			Inputs:  make([]float64, size),
+			Targets: make([]float64, opts.OutputNum),
+		}
+		for j, p := range pixels {
+			line.Inputs[j] = float64(p) / 255
+		}
+		if opts.OutputNum != 0 {
+			line.Targets[label] = 1
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eachLibSVM reads a sparse LIBSVM file. Features left out are zero, and each label is matched against the target
+// labels (by value when both are numbers, so 1 matches +1) to make a one-hot target.
+func eachLibSVM(path string, opts ReadOptions, fn func(Line) error) error {
+	file, err := openData(path)
+	if err != nil {
+		return fmt.Errorf("opening data file: %w", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var lineNum int
+	for scanner.Scan() {
+		lineNum++
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		index, err := libSVMLabelIndex(fields[0], opts.Labels)
+		if err != nil {
+			return InvalidValueError{Line: lineNum, Column: "label", Value: fields[0], Err: err}
+		}
+		line := Line{
+			Inputs:  make([]float64, opts.InputNum),
+			Targets: make([]float64, len(opts.Labels)),
+		}
+		line.Targets[index] = 1
+		for _, field := range fields[1:] {
+			splits := strings.SplitN(field, ":", 2)
+			if len(splits) != 2 {
+				return InvalidValueError{Line: lineNum, Column: "feature", Value: field, Err: fmt.Errorf("expected index:value")}
+			}
+			feature, err := strconv.Atoi(splits[0])
+			if err != nil || feature < 1 || feature > opts.InputNum {
+				return InvalidValueError{Line: lineNum, Column: splits[0], Value: field,
+					Err: fmt.Errorf("feature index must be from 1 to %d", opts.InputNum)}
+			}
+			num, err := strconv.ParseFloat(splits[1], 64)
+			if err != nil {
+				return InvalidValueError{Line: lineNum, Column: splits[0], Value: splits[1], Err: err}
+			}
+			line.Inputs[feature-1] = num
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading lines: %w", err)
+	}
+	return nil
+}
+
+func libSVMLabelIndex(label string, labels []string) (int, error) {
+	num, numErr := strconv.ParseFloat(label, 64)
+	for i, l := range labels {
+		if l == label {
+			return i, nil
+		}
+		if numErr != nil {
+			continue
+		}
+		if n, err := strconv.ParseFloat(l, 64); err == nil && n == num {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("not one of the target labels %s", strings.Join(labels, ","))
+}
+
+// eachSequence reads a sequence file. Each line is any number of steps followed by the targets, separated by
+// semicolons, such as 0.1 0.2; 0.3 0.4; 0 1. The values of each step are separated by the delimiter and become
+// consecutive inputs, so a line's inputs are its steps one after another. The targets are OutputNum values or, when
+// there is more than one output, a single label matched against the target labels.
+func eachSequence(path string, opts ReadOptions, fn func(Line) error) error {
+	file, err := openData(path)
+	if err != nil {
+		return fmt.Errorf("opening data file: %w", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var lineNum int
+	for scanner.Scan() {
+		lineNum++
+		trimmed := strings.TrimSpace(scanner.Text())
+		if trimmed == "" || (opts.Comment != "" && strings.HasPrefix(trimmed, opts.Comment)) {
+			continue
+		}
+		groups := strings.Split(trimmed, ";")
+		if len(groups) < 2 {
+			return fmt.Errorf("at line %d, expected steps and then targets separated by semicolons", lineNum)
+		}
+		steps := groups[:len(groups)-1]
+		line := Line{Inputs: make([]float64, 0, len(steps)*opts.InputNum)}
+		for i, step := range steps {
+			column := fmt.Sprintf("step %d", i+1)
+			values, err := parseGroup(lineNum, column, step, opts)
+			if err != nil {
+				return err
+			}
+			if len(values) != opts.InputNum {
+				return fmt.Errorf("at line %d, expected %d values in %s, got %d", lineNum, opts.InputNum, column,
+					len(values))
+			}
+			line.Inputs = append(line.Inputs, values...)
+		}
+		line.Targets, err = sequenceTargets(lineNum, groups[len(groups)-1], opts)
+		if err != nil {
+			return err
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading lines: %w", err)
+	}
+	return nil
+}
+
+// parseGroup parses the delimited values of one step or the targets of a sequence
+func parseGroup(lineNum int, column, group string, opts ReadOptions) ([]float64, error) {
+	splits, err := opts.split(group)
+	if err != nil {
+		return nil, fmt.Errorf("at line %d, splitting %s: %w", lineNum, column, err)
+	}
+	values := make([]float64, len(splits))
+	for i, split := range splits {
+		values[i], err = strconv.ParseFloat(split, 64)
+		if err != nil {
+			return nil, InvalidValueError{Line: lineNum, Column: column, Value: split, Err: err}
+		}
+	}
+	return values, nil
+}
+
+func sequenceTargets(lineNum int, group string, opts ReadOptions) ([]float64, error) {
+	splits, err := opts.split(group)
+	if err != nil {
+		return nil, fmt.Errorf("at line %d, splitting targets: %w", lineNum, err)
+	}
+	if len(splits) == 1 && opts.OutputNum > 1 && len(opts.Labels) == opts.OutputNum {
+		index, err := libSVMLabelIndex(splits[0], opts.Labels)
+		if err != nil {
+			return nil, InvalidValueError{Line: lineNum, Column: "targets", Value: splits[0], Err: err}
+		}
+		targets := make([]float64, opts.OutputNum)
+		targets[index] = 1
+		return targets, nil
+	}
+	targets, err := parseGroup(lineNum, "targets", group, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) != opts.OutputNum {
+		return nil, fmt.Errorf("at line %d, expected %d targets, got %d", lineNum, opts.OutputNum, len(targets))
+	}
+	return targets, nil
+}
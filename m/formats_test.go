@@ -0,0 +1,252 @@
+package m
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// tempDir makes a directory removed when the test ends
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "gophernet")
+	if err != nil {
+		t.Fatalf("creating directory: %s", err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
+func writeFile(t *testing.T, filename string, data []byte) {
+	t.Helper()
+	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+		t.Fatalf("writing %s: %s", filename, err)
+	}
+}
+
+// idx encodes an IDX file of unsigned bytes with the given dimensions
+func idx(dims []uint32, data ...byte) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{0, 0, idxUnsignedByte, byte(len(dims))})
+	for _, dim := range dims {
+		binary.Write(&buf, binary.BigEndian, dim)
+	}
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+func gzipped(data []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestEachIDX(t *testing.T) {
+	dir := tempDir(t)
+	images := idx([]uint32{2, 2, 2}, 0, 255, 51, 102, 255, 0, 0, 0)
+	labels := idx([]uint32{2}, 2, 0)
+	writeFile(t, filepath.Join(dir, "train-images-idx3-ubyte"), images)
+	writeFile(t, filepath.Join(dir, "train-labels-idx1-ubyte"), labels)
+	writeFile(t, filepath.Join(dir, "train-images-idx3-ubyte.gz"), gzipped(images))
+	writeFile(t, filepath.Join(dir, "train-labels-idx1-ubyte.gz"), gzipped(labels))
+	expected := Lines{
+		{Inputs: []float64{0, 1, 0.2, 0.4}, Targets: []float64{0, 0, 1}},
+		{Inputs: []float64{1, 0, 0, 0}, Targets: []float64{1, 0, 0}},
+	}
+	for _, name := range []string{"train-images-idx3-ubyte", "train-images-idx3-ubyte.gz"} {
+		lines, err := ReadAll(FileDataset{
+			Path:   filepath.Join(dir, name),
+			Format: ReadOptions{Format: FormatIDX, InputNum: 4, OutputNum: 3},
+		})
+		if err != nil {
+			t.Fatalf("%s: reading: %s", name, err)
+		}
+		if !reflect.DeepEqual(lines, expected) {
+			t.Errorf("%s: got %v, expected %v", name, lines, expected)
+		}
+	}
+}
+
+func TestEachIDXWithoutOutputs(t *testing.T) {
+	dir := tempDir(t)
+	writeFile(t, filepath.Join(dir, "images"), idx([]uint32{2, 1, 2}, 255, 0, 51, 102))
+	writeFile(t, filepath.Join(dir, "labels"), idx([]uint32{2}, 7, 9))
+	lines, err := ReadAll(FileDataset{
+		Path:   filepath.Join(dir, "images"),
+		Format: ReadOptions{Format: FormatIDX, LabelsPath: filepath.Join(dir, "labels"), InputNum: 2},
+	})
+	if err != nil {
+		t.Fatalf("reading: %s", err)
+	}
+	expected := Lines{
+		{Inputs: []float64{1, 0}, Targets: []float64{}},
+		{Inputs: []float64{0.2, 0.4}, Targets: []float64{}},
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("got %v, expected %v", lines, expected)
+	}
+}
+
+func TestEachIDXErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		images []byte
+		labels []byte
+		inputs int
+	}{
+		{"not idx", []byte{1, 2, 8, 3, 0, 0, 0, 1}, idx([]uint32{1}, 0), 4},
+		{"floats", append([]byte{0, 0, 0x0d, 1}, 0, 0, 0, 1), idx([]uint32{1}, 0), 4},
+		{"short header", []byte{0, 0, idxUnsignedByte}, idx([]uint32{1}, 0), 4},
+		{"missing dimension", []byte{0, 0, idxUnsignedByte, 3, 0, 0, 0, 1}, idx([]uint32{1}, 0), 4},
+		{"one dimension", idx([]uint32{4}, 0, 0, 0, 0), idx([]uint32{1}, 0), 4},
+		{"labels with two dimensions", idx([]uint32{1, 2, 2}, 0, 0, 0, 0), idx([]uint32{1, 1}, 0), 4},
+		{"count mismatch", idx([]uint32{2, 2, 2}, 0, 0, 0, 0, 0, 0, 0, 0), idx([]uint32{1}, 0), 4},
+		{"size mismatch", idx([]uint32{1, 2, 2}, 0, 0, 0, 0), idx([]uint32{1}, 0), 9},
+		{"truncated image", idx([]uint32{2, 2, 2}, 0, 0, 0, 0, 0), idx([]uint32{2}, 0, 1), 4},
+		{"truncated labels", idx([]uint32{2, 2, 2}, 0, 0, 0, 0, 0, 0, 0, 0), idx([]uint32{2}, 0), 4},
+		{"label outside outputs", idx([]uint32{1, 2, 2}, 0, 0, 0, 0), idx([]uint32{1}, 3), 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := tempDir(t)
+			writeFile(t, filepath.Join(dir, "images"), tt.images)
+			writeFile(t, filepath.Join(dir, "labels"), tt.labels)
+			_, err := ReadAll(FileDataset{
+				Path: filepath.Join(dir, "images"),
+				Format: ReadOptions{Format: FormatIDX, LabelsPath: filepath.Join(dir, "labels"), InputNum: tt.inputs,
+					OutputNum: 3},
+			})
+			if err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestEachLibSVM(t *testing.T) {
+	dir := tempDir(t)
+	filename := filepath.Join(dir, "data.libsvm")
+	writeFile(t, filename, []byte("+1 1:0.5 3:1\n# a comment\n\n-1 2:0.25 # trailing comment\nfoo 4:2\n"))
+	lines, err := ReadAll(FileDataset{
+		Path:   filename,
+		Format: ReadOptions{Format: FormatLibSVM, InputNum: 4, Labels: []string{"1", "-1", "foo"}},
+	})
+	if err != nil {
+		t.Fatalf("reading: %s", err)
+	}
+	expected := Lines{
+		{Inputs: []float64{0.5, 0, 1, 0}, Targets: []float64{1, 0, 0}},
+		{Inputs: []float64{0, 0.25, 0, 0}, Targets: []float64{0, 1, 0}},
+		{Inputs: []float64{0, 0, 0, 2}, Targets: []float64{0, 0, 1}},
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("got %v, expected %v", lines, expected)
+	}
+}
+
+func TestEachLibSVMErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		line int
+	}{
+		{"unknown label", "1 1:0.5\n2 1:0.5\n", 2},
+		{"missing colon", "1 1:0.5\n1 2\n", 2},
+		{"index zero", "1 0:0.5\n", 1},
+		{"index past inputs", "1 5:0.5\n", 1},
+		{"bad index", "1 a:0.5\n", 1},
+		{"bad value", "0 1:0.5\n\n1 2:x\n", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(tempDir(t), "data.libsvm")
+			writeFile(t, filename, []byte(tt.data))
+			_, err := ReadAll(FileDataset{
+				Path:   filename,
+				Format: ReadOptions{Format: FormatLibSVM, InputNum: 4, Labels: []string{"0", "1"}},
+			})
+			var invalid InvalidValueError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("got %v, expected an InvalidValueError", err)
+			}
+			if invalid.Line != tt.line {
+				t.Errorf("got line %d, expected %d", invalid.Line, tt.line)
+			}
+		})
+	}
+}
+
+func TestEachSequence(t *testing.T) {
+	dir := tempDir(t)
+	filename := filepath.Join(dir, "data.seq")
+	writeFile(t, filename, []byte("# steps of two values\n0.1 0.2; 0.3 0.4; up\n\n0.5 0.6; down\n1 2; 3 4; 5 6; 0 1\n"))
+	lines, err := ReadAll(FileDataset{
+		Path: filename,
+		Format: ReadOptions{Format: FormatSequence, Comment: "#", InputNum: 2, OutputNum: 2,
+			Labels: []string{"down", "up"}},
+	})
+	if err != nil {
+		t.Fatalf("reading: %s", err)
+	}
+	expected := Lines{
+		{Inputs: []float64{0.1, 0.2, 0.3, 0.4}, Targets: []float64{0, 1}},
+		{Inputs: []float64{0.5, 0.6}, Targets: []float64{1, 0}},
+		{Inputs: []float64{1, 2, 3, 4, 5, 6}, Targets: []float64{0, 1}},
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("got %v, expected %v", lines, expected)
+	}
+
+	// a single target of a one output sequence is a value, not a label
+	writeFile(t, filename, []byte("1,2;3,4;0.5\n"))
+	lines, err = ReadAll(FileDataset{
+		Path:   filename,
+		Format: ReadOptions{Format: FormatSequence, Delimiter: DelimiterCSV, InputNum: 2, OutputNum: 1},
+	})
+	if err != nil {
+		t.Fatalf("reading csv steps: %s", err)
+	}
+	expected = Lines{{Inputs: []float64{1, 2, 3, 4}, Targets: []float64{0.5}}}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("got %v, expected %v", lines, expected)
+	}
+}
+
+func TestEachSequenceErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"no targets", "0.1 0.2 0.3\n"},
+		{"short step", "0.1 0.2; 0.3; up\n"},
+		{"long step", "0.1 0.2 0.3; up\n"},
+		{"bad value", "0.1 0.2; x 0.4; up\n"},
+		{"unknown label", "0.1 0.2; sideways\n"},
+		{"too many targets", "0.1 0.2; 0 1 0\n"},
+		{"bad target", "0.1 0.2; 0 x\n"},
+		{"bad row after good", "0.1 0.2; up\n0.1; up\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(tempDir(t), "data.seq")
+			writeFile(t, filename, []byte(tt.data))
+			_, err := ReadAll(FileDataset{
+				Path:   filename,
+				Format: ReadOptions{Format: FormatSequence, InputNum: 2, OutputNum: 2, Labels: []string{"down", "up"}},
+			})
+			if err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package m
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/mat"
+	"math"
+	"math/rand"
+)
+
+// Weight initializers. Each draws from the run's RandInit source, so that the same seed starts from the same weights.
+// Biases always start at zero.
+const (
+	// InitFanIn draws from Uniform(±1/√fan_in), which every run used before initializers could be chosen
+	InitFanIn = "fan-in"
+	// InitXavierUniform and InitXavierNormal (Glorot) keep the variance of activations and gradients steady with
+	// Var = 2/(fan_in+fan_out), which suits sigmoid layers
+	InitXavierUniform = "xavier-uniform"
+	InitXavierNormal  = "xavier-normal"
+	// InitHeUniform and InitHeNormal use Var = 2/fan_in, which suits rectified layers
+	InitHeUniform = "he-uniform"
+	InitHeNormal  = "he-normal"
+	// InitLeCun draws from a normal distribution with Var = 1/fan_in, which suits tanh layers on normalized inputs
+	InitLeCun = "lecun"
+	// InitOrthogonal makes the rows or columns of each weight matrix orthonormal
+	InitOrthogonal = "orthogonal"
+)
+
+// Initializers lists the names of every weight initializer
+var Initializers = []string{
+	InitFanIn, InitXavierUniform, InitXavierNormal, InitHeUniform, InitHeNormal, InitLeCun, InitOrthogonal,
+}
+
+// defaultInitializers is the initializer used for each activator when none is chosen
+var defaultInitializers = map[string]string{
+	"sigmoid": InitXavierUniform,
+	"tanh":    InitLeCun,
+}
+
+// DefaultInitializer is the initializer used for an activator when none is chosen
+func DefaultInitializer(activator Activator) string {
+	if init, ok := defaultInitializers[activator.String()]; ok {
+		return init
+	}
+	return InitXavierUniform
+}
+
+// CheckInitializer reports whether an initializer exists
+func CheckInitializer(init string) error {
+	for _, name := range Initializers {
+		if init == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid initializer %s", init)
+}
+
+// initializer is the configured initializer, or the default for the activator
+func (c Config) initializer() string {
+	if c.Init != "" {
+		return c.Init
+	}
+	return DefaultInitializer(c.Activator)
+}
+
+// initialWeights returns the starting weights of a layer with rows outputs and cols inputs in row major order, drawn
+// from rng. A nil rng gives zeros, for a network that will be given the state of a trained one.
+func initialWeights(init string, rng *rand.Rand, rows, cols int) []float64 {
+	if rng == nil {
+		return make([]float64, rows*cols)
+	}
+	fanIn, fanOut := float64(cols), float64(rows)
+	var draw func() float64
+	switch init {
+	case InitFanIn:
+		return randomArray(rng, rows*cols, fanIn)
+	case InitXavierUniform:
+		draw = uniform(rng, math.Sqrt(6/(fanIn+fanOut)))
+	case InitXavierNormal:
+		draw = normal(rng, math.Sqrt(2/(fanIn+fanOut)))
+	case InitHeUniform:
+		draw = uniform(rng, math.Sqrt(6/fanIn))
+	case InitHeNormal:
+		draw = normal(rng, math.Sqrt(2/fanIn))
+	case InitLeCun:
+		draw = normal(rng, math.Sqrt(1/fanIn))
+	case InitOrthogonal:
+		return orthogonal(rng, rows, cols)
+	default:
+		return make([]float64, rows*cols)
+	}
+	data := make([]float64, rows*cols)
+	for i := range data {
+		data[i] = draw()
+	}
+	return data
+}
+
+// uniform draws from Uniform(±limit)
+func uniform(rng *rand.Rand, limit float64) func() float64 {
+	return func() float64 {
+		return (2*rng.Float64() - 1) * limit
+	}
+}
+
+// normal draws from a normal distribution with a mean of zero
+func normal(rng *rand.Rand, sigma float64) func() float64 {
+	return func() float64 {
+		return rng.NormFloat64() * sigma
+	}
+}
+
+// orthogonal returns a random matrix whose rows (or columns, when there are more rows than columns) are orthonormal,
+// taken from the QR decomposition of a matrix of standard normal values
+func orthogonal(rng *rand.Rand, rows, cols int) []float64 {
+	tall, wide := rows, cols
+	if rows < cols {
+		tall, wide = cols, rows
+	}
+	a := mat.NewDense(tall, wide, nil)
+	for i := 0; i < tall; i++ {
+		for j := 0; j < wide; j++ {
+			a.Set(i, j, rng.NormFloat64())
+		}
+	}
+	var qr mat.QR
+	qr.Factorize(a)
+	var q, r mat.Dense
+	qr.QTo(&q)
+	qr.RTo(&r)
+
+	data := make([]float64, rows*cols)
+	for i := 0; i < tall; i++ {
+		for j := 0; j < wide; j++ {
+			// flipping columns to match the sign of R's diagonal makes the distribution uniform
+			v := q.At(i, j)
+			if r.At(j, j) < 0 {
+				v = -v
+			}
+			if rows < cols {
+				data[j*cols+i] = v
+			} else {
+				data[i*cols+j] = v
+			}
+		}
+	}
+	return data
+}
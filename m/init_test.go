@@ -0,0 +1,54 @@
+package m
+
+import (
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// TestInitialWeightsSeeded builds networks of every initializer twice from one seed, which must start from the same
+// weights, and once from another, which must not
+func TestInitialWeightsSeeded(t *testing.T) {
+	for _, init := range Initializers {
+		t.Run(init, func(t *testing.T) {
+			c := Config{
+				InputNum:  4,
+				HiddenNum: 3,
+				OutputNum: 2,
+				LayerNum:  3,
+				Activator: Sigmoid{},
+				Init:      init,
+				Bias:      true,
+				Seed:      7,
+			}
+			first, second := NewNetwork(c), NewNetwork(c)
+			c.Seed = 8
+			other := NewNetwork(c)
+			for i, p := range first.sequential.Parameters() {
+				if !mat.Equal(p.Value, second.sequential.Parameters()[i].Value) {
+					t.Errorf("parameter %d differs between networks of the same seed", i)
+				}
+				if i%2 == 0 && mat.Equal(p.Value, other.sequential.Parameters()[i].Value) {
+					t.Errorf("weights %d are the same for networks of different seeds", i)
+				}
+				if i%2 == 1 && mat.Sum(p.Value) != 0 {
+					t.Errorf("biases %d don't start at zero", i)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckInitializer(t *testing.T) {
+	for _, init := range Initializers {
+		if err := CheckInitializer(init); err != nil {
+			t.Errorf("%s: %s", init, err)
+		}
+	}
+	// zeros would give every node the same weights, which training can't tell apart
+	for _, init := range []string{"zeros", "", "xavier"} {
+		if err := CheckInitializer(init); err == nil {
+			t.Errorf("%q: expected an error", init)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package m
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"strings"
+	"text/tabwriter"
+)
+
+// ColumnStats summarizes the finite values of one column. NaN and infinite values are counted but left out of
+// the other statistics.
+type ColumnStats struct {
+	Min  float64
+	Max  float64
+	Mean float64
+	Std  float64
+	NaN  int
+	Inf  int
+	// OutOfRange counts finite values outside the expected range
+	OutOfRange int
+	count      int
+	m2         float64
+}
+
+func (s *ColumnStats) add(v float64, low, high float64) {
+	if math.IsNaN(v) {
+		s.NaN++
+		return
+	}
+	if math.IsInf(v, 0) {
+		s.Inf++
+		return
+	}
+	if v < low || v > high {
+		s.OutOfRange++
+	}
+	if s.count == 0 {
+		s.Min, s.Max = v, v
+	}
+	s.Min = math.Min(s.Min, v)
+	s.Max = math.Max(s.Max, v)
+	// Welford's online algorithm keeps the variance stable in a single pass
+	s.count++
+	delta := v - s.Mean
+	s.Mean += delta / float64(s.count)
+	s.m2 += delta * (v - s.Mean)
+	s.Std = math.Sqrt(s.m2 / float64(s.count))
+}
+
+// Inspection describes the contents of a dataset so that problems can be found before training
+type Inspection struct {
+	Rows    int
+	Inputs  []ColumnStats
+	Targets []ColumnStats
+	// Low and High are the range inputs are expected to be normalized into
+	Low  float64
+	High float64
+	// RowsOutOfRange counts rows with at least one input outside the expected range
+	RowsOutOfRange int
+	// RowsNotFinite counts rows with at least one NaN or infinite value
+	RowsNotFinite int
+	// Duplicates counts rows identical to an earlier row, and Conflicts counts rows with the same inputs as an
+	// earlier row but different targets
+	Duplicates int
+	Conflicts  int
+	// Classes counts the rows with exactly one hot target at each index
+	Classes []int
+	// NoHot and MultiHot count rows whose targets don't round to exactly one 1
+	NoHot    int
+	MultiHot int
+}
+
+// Inspect reads every line of a dataset and gathers statistics for each column, duplicate rows and the class
+// distribution of the one-hot targets
+func Inspect(dataset Dataset, inputNum, outputNum int, low, high float64) (Inspection, error) {
+	inspection := Inspection{
+		Inputs:  make([]ColumnStats, inputNum),
+		Targets: make([]ColumnStats, outputNum),
+		Classes: make([]int, outputNum),
+		Low:     low,
+		High:    high,
+	}
+	// the first row with each set of inputs is kept under the hash of its inputs, and rows are only counted as the
+	// same when their values are, since different inputs can share a hash
+	seen := make(map[uint64][]Line)
+	err := dataset.Each(func(line Line) error {
+		inspection.Rows++
+		var outOfRange, notFinite bool
+		for i, v := range line.Inputs {
+			// the steps of a sequence are gathered into the statistics of their columns
+			column := &inspection.Inputs[i%inputNum]
+			before := *column
+			column.add(v, low, high)
+			outOfRange = outOfRange || column.OutOfRange > before.OutOfRange
+			notFinite = notFinite || math.IsNaN(v) || math.IsInf(v, 0)
+		}
+		hot := -1
+		hotCount := 0
+		for i, t := range line.Targets {
+			inspection.Targets[i].add(t, math.Inf(-1), math.Inf(1))
+			notFinite = notFinite || math.IsNaN(t) || math.IsInf(t, 0)
+			if int(t+math.Copysign(0.5, t)) == 1 {
+				hot = i
+				hotCount++
+			}
+		}
+		switch hotCount {
+		case 0:
+			inspection.NoHot++
+		case 1:
+			inspection.Classes[hot]++
+		default:
+			inspection.MultiHot++
+		}
+		if outOfRange {
+			inspection.RowsOutOfRange++
+		}
+		if notFinite {
+			inspection.RowsNotFinite++
+		}
+
+		inputHash := hashValues(line.Inputs)
+		for _, previous := range seen[inputHash] {
+			if !sameValues(previous.Inputs, line.Inputs) {
+				continue
+			}
+			if sameValues(previous.Targets, line.Targets) {
+				inspection.Duplicates++
+			} else {
+				inspection.Conflicts++
+			}
+			return nil
+		}
+		seen[inputHash] = append(seen[inputHash], Line{
+			Inputs:  append([]float64(nil), line.Inputs...),
+			Targets: append([]float64(nil), line.Targets...),
+		})
+		return nil
+	})
+	if err != nil {
+		return Inspection{}, err
+	}
+	return inspection, nil
+}
+
+func hashValues(values []float64) uint64 {
+	h := fnv.New64a()
+	for _, v := range values {
+		fmt.Fprintf(h, "%v,", v)
+	}
+	return h.Sum64()
+}
+
+// sameValues reports whether two rows of values are the same, treating NaN as the same as NaN as hashValues does
+func sameValues(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] && !(math.IsNaN(a[i]) && math.IsNaN(b[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// Report writes a readable report. Labels name the classes and may be nil.
+func (inspection Inspection) Report(w io.Writer, labels []string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Rows\t%d\t\n", inspection.Rows)
+	fmt.Fprintf(tw, "Duplicate rows\t%d\t\n", inspection.Duplicates)
+	fmt.Fprintf(tw, "Conflicting rows (same inputs, different targets)\t%d\t\n", inspection.Conflicts)
+	fmt.Fprintf(tw, "Rows with NaN or Inf\t%d\t\n", inspection.RowsNotFinite)
+	fmt.Fprintf(tw, "Rows with inputs outside %g..%g\t%d\t\n", inspection.Low, inspection.High, inspection.RowsOutOfRange)
+	fmt.Fprintf(tw, "Rows with no hot target\t%d\t\n", inspection.NoHot)
+	fmt.Fprintf(tw, "Rows with several hot targets\t%d\t\n", inspection.MultiHot)
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "Class\tRows\tShare\t")
+	for i, count := range inspection.Classes {
+		label := fmt.Sprint(i)
+		if i < len(labels) {
+			label = labels[i]
+		}
+		var share float64
+		if inspection.Rows > 0 {
+			share = 100 * float64(count) / float64(inspection.Rows)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%.2f%%\t\n", label, count, share)
+	}
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "Column\tMin\tMax\tMean\tStd\tNaN\tInf\tOut of range\t")
+	for i, s := range inspection.Inputs {
+		fmt.Fprintf(tw, "input %d\t%s\t%d\t%d\t%d\t\n", i+1, s.summary(), s.NaN, s.Inf, s.OutOfRange)
+	}
+	for i, s := range inspection.Targets {
+		fmt.Fprintf(tw, "target %d\t%s\t%d\t%d\t\t\n", i+1, s.summary(), s.NaN, s.Inf)
+	}
+	return tw.Flush()
+}
+
+func (s ColumnStats) summary() string {
+	values := []float64{s.Min, s.Max, s.Mean, s.Std}
+	formatted := make([]string, len(values))
+	for i, v := range values {
+		formatted[i] = fmt.Sprintf("%.4f", v)
+	}
+	return strings.Join(formatted, "\t")
+}
@@ -0,0 +1,99 @@
+package m
+
+import (
+	"math"
+	"testing"
+)
+
+func TestInspectDuplicatesAndConflicts(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		name       string
+		lines      Lines
+		duplicates int
+		conflicts  int
+	}{
+		{
+			name: "distinct",
+			lines: Lines{
+				{Inputs: []float64{0, 1}, Targets: []float64{1, 0}},
+				{Inputs: []float64{1, 0}, Targets: []float64{1, 0}},
+				{Inputs: []float64{1, 1}, Targets: []float64{0, 1}},
+			},
+		},
+		{
+			name: "duplicates",
+			lines: Lines{
+				{Inputs: []float64{0, 1}, Targets: []float64{1, 0}},
+				{Inputs: []float64{0, 1}, Targets: []float64{1, 0}},
+				{Inputs: []float64{0, 1}, Targets: []float64{1, 0}},
+				{Inputs: []float64{1, 0}, Targets: []float64{0, 1}},
+			},
+			duplicates: 2,
+		},
+		{
+			name: "conflicts",
+			lines: Lines{
+				{Inputs: []float64{0, 1}, Targets: []float64{1, 0}},
+				{Inputs: []float64{0, 1}, Targets: []float64{0, 1}},
+				{Inputs: []float64{0, 1}, Targets: []float64{0, 1}},
+			},
+			conflicts: 2,
+		},
+		{
+			name: "conflicts are with the first row",
+			lines: Lines{
+				{Inputs: []float64{0.5, 0.5}, Targets: []float64{1, 0}},
+				{Inputs: []float64{0.5, 0.5}, Targets: []float64{0, 1}},
+				{Inputs: []float64{0.5, 0.5}, Targets: []float64{1, 0}},
+			},
+			duplicates: 1,
+			conflicts:  1,
+		},
+		{
+			name: "nan",
+			lines: Lines{
+				{Inputs: []float64{nan, 1}, Targets: []float64{1, 0}},
+				{Inputs: []float64{nan, 1}, Targets: []float64{1, 0}},
+				{Inputs: []float64{nan, 0}, Targets: []float64{1, 0}},
+			},
+			duplicates: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inspection, err := Inspect(tt.lines, 2, 2, 0, 1)
+			if err != nil {
+				t.Fatalf("inspecting: %s", err)
+			}
+			if inspection.Rows != len(tt.lines) {
+				t.Errorf("got %d rows, expected %d", inspection.Rows, len(tt.lines))
+			}
+			if inspection.Duplicates != tt.duplicates {
+				t.Errorf("got %d duplicates, expected %d", inspection.Duplicates, tt.duplicates)
+			}
+			if inspection.Conflicts != tt.conflicts {
+				t.Errorf("got %d conflicts, expected %d", inspection.Conflicts, tt.conflicts)
+			}
+		})
+	}
+}
+
+func TestSameValues(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		a, b []float64
+		same bool
+	}{
+		{[]float64{0, 1}, []float64{0, 1}, true},
+		{[]float64{0, 1}, []float64{1, 0}, false},
+		{[]float64{0, 1}, []float64{0, 1, 0}, false},
+		{[]float64{nan}, []float64{nan}, true},
+		{[]float64{nan}, []float64{0}, false},
+	}
+	for _, tt := range tests {
+		if same := sameValues(tt.a, tt.b); same != tt.same {
+			t.Errorf("sameValues(%v, %v) is %t, expected %t", tt.a, tt.b, same, tt.same)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package m
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/mat"
+	"math/rand"
+)
+
+// Layer is one step of a Sequential model. Values pass between layers as column vectors.
+type Layer interface {
+	// Forward returns the layer's output for an input. While training, a layer keeps what Backward needs from the
+	// last Forward. Otherwise it changes nothing, so a trained layer is safe for concurrent predictions.
+	Forward(inputs mat.Matrix, training bool) mat.Matrix
+	// Backward takes the errors of the output of the last Forward, sets the gradients of the layer's parameters and
+	// returns the errors of its input
+	Backward(errors Errors) Errors
+	// Parameters are the values training updates, which is none for layers that don't learn
+	Parameters() []*Parameter
+	// State is what is saved to restore the layer, keyed by file extension, and SetState restores it
+	State() map[string]*mat.Dense
+	SetState(state map[string]*mat.Dense) error
+	fmt.Stringer
+}
+
+// Errors is what Backward carries from a layer's output back to its input. Error is each node's error, which passes
+// back through the weights without the derivatives of the activations, as this network always has. Gradient is the
+// error each layer's parameters are updated from, which an activation takes from Error scaled by its derivative.
+type Errors struct {
+	Error    mat.Matrix
+	Gradient mat.Matrix
+}
+
+// Parameter is a matrix of values learned by a layer and the last gradient found by Backward, which is a negative
+// gradient of the loss that is added to the values. L1, L2 and MaxNorm regularize the values after each update.
+type Parameter struct {
+	Value    *mat.Dense
+	Gradient *mat.Dense
+	L1       float64
+	L2       float64
+	// MaxNorm limits the norm of each row, which is a node's incoming weights, or is 0 for no limit
+	MaxNorm float64
+}
+
+// Sequential passes values through its layers in order
+type Sequential struct {
+	Layers []Layer
+}
+
+// Forward feeds an input through every layer
+func (s *Sequential) Forward(inputs mat.Matrix, training bool) mat.Matrix {
+	for _, layer := range s.Layers {
+		inputs = layer.Forward(inputs, training)
+	}
+	return inputs
+}
+
+// Backward carries the errors of the output back through every layer, setting the gradient of every parameter
+func (s *Sequential) Backward(errors Errors) {
+	for i := len(s.Layers) - 1; i >= 0; i-- {
+		errors = s.Layers[i].Backward(errors)
+	}
+}
+
+// Parameters are the parameters of every layer, in order
+func (s *Sequential) Parameters() []*Parameter {
+	var parameters []*Parameter
+	for _, layer := range s.Layers {
+		parameters = append(parameters, layer.Parameters()...)
+	}
+	return parameters
+}
+
+// String lists the layers
+func (s *Sequential) String() string {
+	var description string
+	for i, layer := range s.Layers {
+		if i > 0 {
+			description += " -> "
+		}
+		description += layer.String()
+	}
+	return description
+}
+
+// files names the file holding each part of each layer's state. Files are numbered by how many layers with weights
+// come before, so the weights and biases of the first dense layer are in <prefix>0.wgt and <prefix>0.bias and the
+// normalization after it in <prefix>1.norm.
+func (s *Sequential) files(prefix string, fn func(i int, ext, filename string) error) error {
+	var weighted int
+	for i, layer := range s.Layers {
+		state := layer.State()
+		index := weighted
+		if _, ok := state["wgt"]; ok {
+			weighted++
+		}
+		for ext := range state {
+			err := fn(i, ext, fmt.Sprintf("%s%d.%s", prefix, index, ext))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// states is the state of every layer keyed by the file it's saved in
+func (s *Sequential) states(prefix string) map[string]*mat.Dense {
+	states := make(map[string]*mat.Dense)
+	s.files(prefix, func(i int, ext, filename string) error {
+		states[filename] = s.Layers[i].State()[ext]
+		return nil
+	})
+	return states
+}
+
+// setStates restores the state of every layer, reading each of its files
+func (s *Sequential) setStates(prefix string, read func(filename string) (*mat.Dense, error)) error {
+	states := make([]map[string]*mat.Dense, len(s.Layers))
+	err := s.files(prefix, func(i int, ext, filename string) error {
+		d, err := read(filename)
+		if err != nil {
+			return err
+		}
+		if states[i] == nil {
+			states[i] = make(map[string]*mat.Dense)
+		}
+		states[i][ext] = d
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i, state := range states {
+		if state == nil {
+			continue
+		}
+		if err := s.Layers[i].SetState(state); err != nil {
+			return fmt.Errorf("layer %d (%s): %w", i, s.Layers[i], err)
+		}
+	}
+	return nil
+}
+
+// save writes the state of every layer to files starting with prefix
+func (s *Sequential) save(prefix string) error {
+	for filename, d := range s.states(prefix) {
+		if err := saveMatrix(filename, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load reads the state of every layer from files starting with prefix
+func (s *Sequential) load(prefix string) error {
+	return s.setStates(prefix, loadMatrix)
+}
+
+// copyTo gives the layers of another Sequential, such as one without dropout for prediction, copies of this one's
+// state
+func (s *Sequential) copyTo(other *Sequential) error {
+	states := s.states("")
+	return other.setStates("", func(filename string) (*mat.Dense, error) {
+		d, ok := states[filename]
+		if !ok {
+			return nil, fmt.Errorf("no state for %s", filename)
+		}
+		return mat.DenseCopyOf(d), nil
+	})
+}
+
+// sequential composes the network the config describes: its convolutional or recurrent layers, if any, and then its
+// fully connected layers. Each dense layer is followed by its normalization, if any, then its activation and then its
+// dropout. Weights are drawn from initial by the configured initializer, and dropout masks from dropout. Both are nil
+// when the network is only used for prediction, which starts from zeroed weights without dropout.
+func (c Config) sequential(initial, dropout *rand.Rand) *Sequential {
+	init := c.initializer()
+	layers, features := c.convolutional(init, initial)
+	if len(c.RNN) > 0 {
+		layers, features = c.recurrent(init, initial)
+	}
+	s := &Sequential{Layers: layers}
+	sizes := make([]int, c.LayerNum)
+	for i := range sizes {
+		switch i {
+		case 0:
+			sizes[i] = features
+		case c.LayerNum - 1:
+			sizes[i] = c.OutputNum
+		case c.bottleneck():
+			sizes[i] = c.Bottleneck
+		default:
+			sizes[i] = c.HiddenNum
+		}
+	}
+	for i := 0; i < c.LayerNum; i++ {
+		if i > 0 {
+			weights := mat.NewDense(sizes[i], sizes[i-1], initialWeights(init, initial, sizes[i], sizes[i-1]))
+			var biases *mat.Dense
+			if c.Bias {
+				biases = mat.NewDense(sizes[i], 1, nil)
+			}
+			s.Layers = append(s.Layers, NewDense(weights, biases, c.L1, c.L2, c.maxNorm(i-1)))
+			if kind := c.normFor(i); kind != NormNone {
+				s.Layers = append(s.Layers, NewNormalization(kind, sizes[i]))
+			}
+			activation := NewActivation(c.activatorFor(i))
+			// the cross-entropy error at the output already includes the sigmoid's or softmax's derivative
+			activation.lossDerivative = i == c.LayerNum-1 && (c.loss() == LossBCE || c.loss() == LossCE)
+			s.Layers = append(s.Layers, activation)
+		}
+		if rate := c.dropoutRate(i); rate > 0 && dropout != nil {
+			s.Layers = append(s.Layers, NewDropout(rate, dropout))
+		}
+	}
+	return s
+}
+
+// activatorFor is the activator of layer i, which differs from the configured one at the output in regression and
+// multi-label mode and with the categorical cross-entropy loss
+func (c Config) activatorFor(i int) Activator {
+	if i == c.LayerNum-1 {
+		return outputActivator(c.Mode, c.Loss, c.Activator)
+	}
+	return c.Activator
+}
@@ -0,0 +1,107 @@
+package m
+
+import (
+	"gonum.org/v1/gonum/mat"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// layerConfig has a dense layer with biases, a normalization and dropout between the inputs and the outputs
+var layerConfig = Config{InputNum: 4, HiddenNum: 3, OutputNum: 2, LayerNum: 3, Activator: Sigmoid{}, Bias: true,
+	Norm: []string{NormBatch}, Dropout: []float64{0.5}}
+
+// trainedSequential is a network whose weights and running statistics differ from a new one's
+func trainedSequential(t *testing.T) *Sequential {
+	t.Helper()
+	s := layerConfig.sequential(rand.New(rand.NewSource(1)), rand.New(rand.NewSource(2)))
+	inputs := mat.NewDense(layerConfig.InputNum, 1, nil)
+	rng := rand.New(rand.NewSource(3))
+	for i := 0; i < 10; i++ {
+		inputs.Apply(func(_, _ int, _ float64) float64 { return rng.Float64() }, inputs)
+		s.Forward(inputs, true)
+	}
+	return s
+}
+
+func TestSequentialFiles(t *testing.T) {
+	var filenames []string
+	err := trainedSequential(t).files("out/fish-1-", func(i int, ext, filename string) error {
+		filenames = append(filenames, filename)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("naming files: %s", err)
+	}
+	sort.Strings(filenames)
+	// the normalization after the first dense layer is numbered after it, and dropout and activations have no files
+	expected := []string{"out/fish-1-0.bias", "out/fish-1-0.wgt", "out/fish-1-1.bias", "out/fish-1-1.norm",
+		"out/fish-1-1.wgt"}
+	if !reflect.DeepEqual(filenames, expected) {
+		t.Errorf("got %v, expected %v", filenames, expected)
+	}
+}
+
+// samePredictions checks that two networks give the same outputs outside of training
+func samePredictions(t *testing.T, a, b *Sequential) {
+	t.Helper()
+	inputs := mat.NewDense(layerConfig.InputNum, 1, []float64{0.1, 0.9, 0.4, 0.3})
+	if got, expected := b.Forward(inputs, false), a.Forward(inputs, false); !mat.EqualApprox(got, expected, 1e-12) {
+		t.Errorf("got outputs %v, expected %v", mat.Formatted(got.T()), mat.Formatted(expected.T()))
+	}
+}
+
+func TestSequentialSaveLoad(t *testing.T) {
+	trained := trainedSequential(t)
+	prefix := filepath.Join(tempDir(t), "fish-1-")
+	if err := trained.save(prefix); err != nil {
+		t.Fatalf("saving: %s", err)
+	}
+	// a network for prediction has no dropout layers, so its output layer is at a different index
+	loaded := layerConfig.sequential(nil, nil)
+	if err := loaded.load(prefix); err != nil {
+		t.Fatalf("loading: %s", err)
+	}
+	saved, restored := trained.states(prefix), loaded.states(prefix)
+	if len(restored) != len(saved) {
+		t.Fatalf("got %d states, expected %d", len(restored), len(saved))
+	}
+	for filename, d := range saved {
+		if !mat.Equal(restored[filename], d) {
+			t.Errorf("%s got %v, expected %v", filename, mat.Formatted(restored[filename]), mat.Formatted(d))
+		}
+	}
+	samePredictions(t, trained, loaded)
+
+	// a missing file fails the load
+	if err := layerConfig.sequential(nil, nil).load(filepath.Join(filepath.Dir(prefix), "missing-")); err == nil {
+		t.Error("expected an error loading missing files")
+	}
+}
+
+func TestSequentialCopyTo(t *testing.T) {
+	trained := trainedSequential(t)
+	copied := layerConfig.sequential(nil, nil)
+	if err := trained.copyTo(copied); err != nil {
+		t.Fatalf("copying: %s", err)
+	}
+	samePredictions(t, trained, copied)
+
+	// the copy doesn't share the trained network's matrices, so training on doesn't change it
+	before := mat.DenseCopyOf(copied.Parameters()[0].Value)
+	trained.Parameters()[0].Value.Set(0, 0, 42)
+	if !mat.Equal(copied.Parameters()[0].Value, before) {
+		t.Error("changing the trained weights changed the copy")
+	}
+
+	// layers that don't match have nothing to copy from
+	other := layerConfig
+	other.Bias = false
+	other.Norm = nil
+	other.LayerNum = 4
+	if err := trained.copyTo(other.sequential(nil, nil)); err == nil {
+		t.Error("expected an error copying to a network with more layers")
+	}
+}
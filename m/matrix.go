@@ -2,8 +2,8 @@ package m
 
 import (
 	"gonum.org/v1/gonum/mat"
-	"gonum.org/v1/gonum/stat/distuv"
 	"math"
+	"math/rand"
 )
 
 func dot(m, n mat.Matrix) mat.Matrix {
@@ -59,15 +59,12 @@ func subtract(m, n mat.Matrix) mat.Matrix {
 	return o
 }
 
-func randomArray(size int, v float64) []float64 {
-	dist := distuv.Uniform{
-		Min: -1 / math.Sqrt(v),
-		Max: 1 / math.Sqrt(v),
-	}
+func randomArray(rng *rand.Rand, size int, v float64) []float64 {
+	draw := uniform(rng, 1/math.Sqrt(v))
 
 	data := make([]float64, size)
 	for i := 0; i < size; i++ {
-		data[i] = dist.Rand()
+		data[i] = draw()
 	}
 	return data
 }
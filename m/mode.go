@@ -0,0 +1,251 @@
+package m
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Modes decide how a network's outputs are activated, trained and evaluated
+const (
+	// ModeClassification picks the target label with the highest output and is measured by accuracy
+	ModeClassification = "classification"
+	// ModeRegression has linear outputs that predict continuous targets and is measured by MAE, RMSE and R²
+	ModeRegression = "regression"
+	// ModeMultiLabel has independent sigmoid outputs, any number of which can be true at once, and is measured by
+	// subset accuracy, Hamming loss and F1
+	ModeMultiLabel = "multilabel"
+	// ModeAutoencoder learns to reproduce its inputs through a bottleneck and is measured like regression, by how
+	// far its reconstructions are from the inputs
+	ModeAutoencoder = "autoencoder"
+)
+
+// Loss functions minimized by training
+const (
+	LossMSE   = "mse"
+	LossHuber = "huber"
+	// LossBCE is binary cross-entropy, which needs sigmoid outputs
+	LossBCE = "bce"
+	// LossCE is categorical cross-entropy, which gives a classifier softmax outputs
+	LossCE = "ce"
+)
+
+// DefaultThreshold is the output above which a label is predicted in multi-label mode
+const DefaultThreshold = 0.5
+
+// modeOrDefault treats an empty mode as classification, which every run was before modes existed
+func modeOrDefault(mode string) string {
+	if mode == "" {
+		return ModeClassification
+	}
+	return mode
+}
+
+// CheckMode reports whether a mode, loss and activator can be trained together
+func CheckMode(mode, loss string, activator Activator) error {
+	switch modeOrDefault(mode) {
+	case ModeClassification, ModeRegression, ModeMultiLabel, ModeAutoencoder:
+	default:
+		return fmt.Errorf("invalid mode %s", mode)
+	}
+	switch loss {
+	case "", LossMSE, LossHuber:
+	case LossCE:
+		if modeOrDefault(mode) != ModeClassification {
+			return fmt.Errorf("the %s loss needs softmax outputs, which only classifiers have", loss)
+		}
+	case LossBCE:
+		if _, ok := outputActivator(mode, loss, activator).(Sigmoid); !ok {
+			return fmt.Errorf("the %s loss needs sigmoid outputs, use the sigmoid activator or multilabel mode", loss)
+		}
+	default:
+		return fmt.Errorf("invalid loss %s", loss)
+	}
+	return nil
+}
+
+// outputActivator is the activator of the output layer, which is linear in regression mode, sigmoid in multi-label
+// mode and softmax for a classifier trained with categorical cross-entropy
+func outputActivator(mode, loss string, activator Activator) Activator {
+	if loss == LossCE {
+		return Softmax{}
+	}
+	switch mode {
+	case ModeRegression:
+		return Linear{}
+	case ModeMultiLabel:
+		return Sigmoid{}
+	}
+	return activator
+}
+
+// loss is the loss function used, which defaults to binary cross-entropy in multi-label mode and the mean squared
+// error otherwise
+func (c Config) loss() string {
+	if c.Loss != "" {
+		return c.Loss
+	}
+	if c.Mode == ModeMultiLabel {
+		return LossBCE
+	}
+	return LossMSE
+}
+
+// lossOf returns the loss of a single output along with the error that is backpropagated from it. The binary
+// cross-entropy error is already the gradient of the weighted sum, since the sigmoid's derivative cancels out, and so
+// is the categorical cross-entropy error of a softmax output, whose loss only comes from the target label.
+func (c Config) lossOf(target, output float64) (loss, err float64) {
+	diff := target - output
+	switch c.loss() {
+	case LossCE:
+		return -target * math.Log(math.Max(1e-12, output)), diff
+	case LossBCE:
+		o := math.Max(1e-12, math.Min(1-1e-12, output))
+		return -(target*math.Log(o) + (1-target)*math.Log(1-o)), diff
+	case LossHuber:
+		delta := c.huberDelta()
+		if math.Abs(diff) <= delta {
+			return diff * diff / 2, diff
+		}
+		return delta * (math.Abs(diff) - delta/2), math.Copysign(delta, diff)
+	}
+	return diff * diff, diff
+}
+
+func (c Config) huberDelta() float64 {
+	if c.HuberDelta > 0 {
+		return c.HuberDelta
+	}
+	return 1
+}
+
+// settings describes how a run was trained beyond the columns of the analysis log, as space separated key=value
+// pairs
+func (c Config) settings() string {
+	settings := make(map[string]string)
+	settings["loss"] = c.loss()
+	settings["init"] = c.initializer()
+	settings["seed"] = strconv.FormatInt(c.Seed, 10)
+	if c.Bias {
+		settings["bias"] = "true"
+	}
+	if c.L1 != 0 {
+		settings["l1"] = strconv.FormatFloat(c.L1, 'g', -1, 64)
+	}
+	if c.L2 != 0 {
+		settings["l2"] = strconv.FormatFloat(c.L2, 'g', -1, 64)
+	}
+	if len(c.MaxNorm) > 0 {
+		settings["maxnorm"] = formatFloats(c.MaxNorm)
+	}
+	if len(c.Norm) > 0 {
+		settings["norm"] = strings.Join(c.Norm, ",")
+	}
+	if len(c.CNN) > 0 {
+		settings["cnn"] = formatCNN(c.CNN)
+		settings["image"] = fmt.Sprintf("%dx%d", c.ImageWidth, c.ImageHeight)
+	}
+	if len(c.RNN) > 0 {
+		settings["rnn"] = formatRNN(c.RNN)
+	}
+	if c.Mode == ModeAutoencoder {
+		settings["bottleneck"] = strconv.Itoa(c.Bottleneck)
+		settings["targets"] = strconv.Itoa(c.Format.OutputNum)
+	}
+	if len(c.Dropout) > 0 {
+		settings["dropout"] = formatFloats(c.Dropout)
+	}
+	if c.ClipValue != 0 {
+		settings["clipvalue"] = strconv.FormatFloat(c.ClipValue, 'g', -1, 64)
+	}
+	if c.ClipNorm != 0 {
+		settings["clipnorm"] = strconv.FormatFloat(c.ClipNorm, 'g', -1, 64)
+	}
+	if c.loss() == LossHuber {
+		settings["delta"] = strconv.FormatFloat(c.huberDelta(), 'g', -1, 64)
+	}
+	if c.Mode == ModeMultiLabel {
+		settings["threshold"] = formatFloats(c.thresholds())
+	}
+	return formatPairs(settings)
+}
+
+func formatPairs(pairs map[string]string) string {
+	keys := make([]string, 0, len(pairs))
+	for key := range pairs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	formatted := make([]string, len(keys))
+	for i, key := range keys {
+		formatted[i] = key + "=" + pairs[key]
+	}
+	return strings.Join(formatted, " ")
+}
+
+func parsePairs(s string) map[string]string {
+	pairs := make(map[string]string)
+	for _, field := range strings.Fields(s) {
+		splits := strings.SplitN(field, "=", 2)
+		if len(splits) == 2 {
+			pairs[splits[0]] = splits[1]
+		}
+	}
+	return pairs
+}
+
+// thresholds is the decision threshold of each output in multi-label mode
+func (c Config) thresholds() []float64 {
+	if len(c.Thresholds) > 0 {
+		return c.Thresholds
+	}
+	threshold := c.Threshold
+	if threshold == 0 {
+		threshold = DefaultThreshold
+	}
+	thresholds := make([]float64, c.OutputNum)
+	for i := range thresholds {
+		thresholds[i] = threshold
+	}
+	return thresholds
+}
+
+// formatFloats joins values with commas, or gives a single value when they're all the same
+func formatFloats(values []float64) string {
+	formatted := make([]string, len(values))
+	same := true
+	for i, v := range values {
+		formatted[i] = strconv.FormatFloat(v, 'g', -1, 64)
+		same = same && formatted[i] == formatted[0]
+	}
+	if same && len(values) > 0 {
+		return formatted[0]
+	}
+	return strings.Join(formatted, ",")
+}
+
+// ParseThresholds reads a single threshold for every output or a comma separated threshold per output
+func ParseThresholds(s string, outputNum int) ([]float64, error) {
+	splits := strings.Split(s, ",")
+	if len(splits) != 1 && len(splits) != outputNum {
+		return nil, fmt.Errorf("expected 1 or %d thresholds, got %d", outputNum, len(splits))
+	}
+	thresholds := make([]float64, outputNum)
+	for i := range thresholds {
+		split := splits[0]
+		if len(splits) > 1 {
+			split = splits[i]
+		}
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(split), 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing threshold %d: %w", i+1, err)
+		}
+		if threshold <= 0 || threshold >= 1 {
+			return nil, fmt.Errorf("threshold %g must be between 0 and 1", threshold)
+		}
+		thresholds[i] = threshold
+	}
+	return thresholds, nil
+}
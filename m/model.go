@@ -0,0 +1,436 @@
+package m
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/mat"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Model is a trained network used only for inference. A Model is never modified after it is created, and its layers
+// keep nothing from a forward pass that isn't training, so a single Model is safe for concurrent use.
+type Model struct {
+	name       string
+	run        string
+	sequential *Sequential
+	inputNum   int
+	activator  Activator
+	labels     []string
+	transform  *Schema
+	mode       string
+	// thresholds decide which labels are predicted in multi-label mode
+	thresholds []float64
+	// encoder is the number of layers that encode an autoencoder's inputs, or 0 for any other model
+	encoder int
+	// targetColumns is the number of target columns after the inputs of the data an autoencoder was trained on,
+	// which it ignores
+	targetColumns int
+	// members are the models an ensemble combines instead of having layers of its own, weighed by weights
+	members     []*Model
+	weights     []float64
+	combination string
+}
+
+func newModel(sequential *Sequential, inputNum int, activator Activator, labels []string) *Model {
+	return &Model{
+		sequential: sequential,
+		inputNum:   inputNum,
+		activator:  activator,
+		labels:     labels,
+		mode:       ModeClassification,
+	}
+}
+
+// Predict returns the label of the output with the highest score, the comma separated labels above their thresholds
+// in multi-label mode, or the output values in regression and autoencoder mode
+func (model *Model) Predict(inputData []float64) string {
+	if model.mode == ModeRegression || model.mode == ModeAutoencoder {
+		values := model.PredictValues(inputData)
+		formatted := make([]string, len(values))
+		for i, v := range values {
+			formatted[i] = strconv.FormatFloat(v, 'g', -1, 64)
+		}
+		return strings.Join(formatted, ", ")
+	}
+	return model.PredictScores(inputData).Label
+}
+
+// PredictScores feeds the input forward and returns every target label ranked by its output. In regression mode
+// the outputs are left in label order and the prediction holds their values instead of a label.
+func (model *Model) PredictScores(inputData []float64) Prediction {
+	switch model.mode {
+	case ModeRegression, ModeAutoencoder:
+		return newRegressionPrediction(model.PredictValues(inputData), model.labels)
+	case ModeMultiLabel:
+		// sigmoid outputs are only the probabilities of their labels when trained with cross-entropy
+		return newMultiLabelPrediction(model.outputs(inputData), model.labels, model.thresholds,
+			model.outputActivation().lossDerivative && !model.voted())
+	}
+	return newPrediction(model.outputs(inputData), model.labels, model.calibrator())
+}
+
+// calibrator reads the outputs as probabilities, or is nil when they aren't. The votes of an ensemble are the
+// fraction of its members that chose each label, which are never probabilities.
+func (model *Model) calibrator() Calibrator {
+	if model.voted() {
+		return nil
+	}
+	calibrator, _ := model.outputActivation().Activator().(Calibrator)
+	return calibrator
+}
+
+// voted reports whether the model is an ensemble that combines its members' votes rather than averaging them
+func (model *Model) voted() bool {
+	return model.members != nil && model.combination != CombineAverage
+}
+
+// outputActivation is the activation of the output layer, whose activator and loss decide whether the outputs are
+// probabilities. An ensemble's outputs are only as calibrated as its least calibrated member's, so it takes that
+// member's activation.
+func (model *Model) outputActivation() *Activation {
+	if model.members != nil {
+		for _, member := range model.members {
+			if activation := member.outputActivation(); !activation.lossDerivative {
+				return activation
+			}
+		}
+		for _, member := range model.members {
+			if activation := member.outputActivation(); !isCalibrator(activation.Activator()) {
+				return activation
+			}
+		}
+		return model.members[0].outputActivation()
+	}
+	for i := len(model.sequential.Layers) - 1; i >= 0; i-- {
+		if activation, ok := model.sequential.Layers[i].(*Activation); ok {
+			return activation
+		}
+	}
+	return NewActivation(model.activator)
+}
+
+// PredictValues returns the output values. When the model was trained on data prepared from a schema with a numeric
+// target, the values are scaled back into the target's original range.
+func (model *Model) PredictValues(inputData []float64) []float64 {
+	return model.denormalize(model.outputs(inputData))
+}
+
+// denormalize scales normalized target values back into the range of a numeric target column, in place. An
+// autoencoder's values are inputs, which are left as they are.
+func (model *Model) denormalize(values []float64) []float64 {
+	if model.transform == nil || model.mode == ModeAutoencoder {
+		return values
+	}
+	target := model.transform.targetColumn()
+	if target.Type != ColumnNumeric || target.Min == nil || target.Max == nil {
+		return values
+	}
+	for i, v := range values {
+		values[i] = *target.Min + v*(*target.Max-*target.Min)
+	}
+	return values
+}
+
+func (model *Model) outputs(inputData []float64) []float64 {
+	if model.members != nil {
+		return model.combine(inputData)
+	}
+	outputs := model.sequential.Forward(mat.NewDense(len(inputData), 1, inputData), false)
+	return mat.Col(nil, 0, outputs)
+}
+
+// CheckInput returns an error if the number of input values doesn't match the model's input layer, or for a
+// sequence model isn't a whole number of steps
+func (model *Model) CheckInput(inputData []float64) error {
+	if model.Sequence() {
+		if len(inputData) == 0 || len(inputData)%model.InputNum() != 0 {
+			return fmt.Errorf("expected steps of %d input values, got %d values", model.InputNum(), len(inputData))
+		}
+		return nil
+	}
+	if len(inputData) != model.InputNum() {
+		return fmt.Errorf("expected %d input values, got %d", model.InputNum(), len(inputData))
+	}
+	return nil
+}
+
+// InputNum is the number of values expected by the input layer, which for a sequence model is the number in each
+// step
+func (model *Model) InputNum() int {
+	return model.inputNum
+}
+
+// Sequence reports whether the model reads its input as a sequence of any number of steps, as a recurrent network
+// does
+func (model *Model) Sequence() bool {
+	if model.members != nil {
+		return model.members[0].Sequence()
+	}
+	if len(model.sequential.Layers) == 0 {
+		return false
+	}
+	_, ok := model.sequential.Layers[0].(*Recurrent)
+	return ok
+}
+
+// OutputNum is the number of output nodes, one per target label
+func (model *Model) OutputNum() int {
+	if model.members != nil {
+		return model.members[0].OutputNum()
+	}
+	for i := len(model.sequential.Layers) - 1; i >= 0; i-- {
+		if dense, ok := model.sequential.Layers[i].(*Dense); ok {
+			_, outputs := dense.Size()
+			return outputs
+		}
+	}
+	return 0
+}
+
+// Name is the name of the dataset the model was trained on
+func (model *Model) Name() string {
+	return model.name
+}
+
+// Run is the ending time identifying the training run the model was saved from, or the + separated ending times of
+// an ensemble's members
+func (model *Model) Run() string {
+	return model.run
+}
+
+// Activator is the name of the activation function used by every layer
+func (model *Model) Activator() string {
+	return model.activator.String()
+}
+
+// Mode is classification, regression or multilabel
+func (model *Model) Mode() string {
+	return model.mode
+}
+
+// Thresholds are the outputs above which each label is predicted in multi-label mode
+func (model *Model) Thresholds() []float64 {
+	return model.thresholds
+}
+
+// WithThresholds returns a copy of a multi-label model that predicts labels using different thresholds
+func (model *Model) WithThresholds(thresholds []float64) (*Model, error) {
+	if model.mode != ModeMultiLabel {
+		return nil, fmt.Errorf("thresholds only apply to multi-label models, %s run %s is %s", model.name, model.run, model.mode)
+	}
+	if len(thresholds) != model.OutputNum() {
+		return nil, fmt.Errorf("expected %d thresholds, got %d", model.OutputNum(), len(thresholds))
+	}
+	copied := *model
+	copied.thresholds = thresholds
+	return &copied, nil
+}
+
+// HasTransform reports whether the model was trained on data prepared from a schema, so that it can encode raw values
+func (model *Model) HasTransform() bool {
+	return model.transform != nil
+}
+
+// EncodeRaw applies the schema the training data was prepared with to raw values keyed by column name, giving
+// inputs for the model
+func (model *Model) EncodeRaw(values map[string]string) ([]float64, error) {
+	if model.transform == nil {
+		return nil, fmt.Errorf("%s run %s was not trained with a saved transform", model.name, model.run)
+	}
+	return model.transform.EncodeInputs(values)
+}
+
+// TargetColumns is the number of target columns after the inputs of the data an autoencoder was trained on, which
+// its data files are read with by default
+func (model *Model) TargetColumns() int {
+	return model.targetColumns
+}
+
+// Labels returns the target label for each output node
+func (model *Model) Labels() []string {
+	return model.labels
+}
+
+// Evaluation is how well a model predicted a labelled dataset
+type Evaluation struct {
+	Correct int
+	Total   int
+	// Accuracy is the percent of lines predicted correctly
+	Accuracy float64
+	// MAE, RMSE and R2 are the mean absolute error, root mean squared error and coefficient of determination of a
+	// regression, averaged over its outputs
+	MAE  float64
+	RMSE float64
+	R2   float64
+	// HammingLoss is the fraction of labels predicted wrongly in multi-label mode, where Accuracy is the percent
+	// of lines with every label right. MicroF1 pools every label's predictions while MacroF1 averages the F1 of each.
+	HammingLoss float64
+	MicroF1     float64
+	MacroF1     float64
+}
+
+// multiLabelMetrics formats the multi-label metrics besides accuracy for the analysis log
+func (evaluation Evaluation) multiLabelMetrics() string {
+	return formatPairs(map[string]string{
+		"hamming": strconv.FormatFloat(evaluation.HammingLoss, 'f', 5, 64),
+		"microf1": strconv.FormatFloat(evaluation.MicroF1, 'f', 5, 64),
+		"macrof1": strconv.FormatFloat(evaluation.MacroF1, 'f', 5, 64),
+	})
+}
+
+// metrics formats the regression metrics as space separated key=value pairs for the analysis log
+func (evaluation Evaluation) metrics() string {
+	return formatPairs(map[string]string{
+		"mae":  strconv.FormatFloat(evaluation.MAE, 'f', 5, 64),
+		"rmse": strconv.FormatFloat(evaluation.RMSE, 'f', 5, 64),
+		"r2":   strconv.FormatFloat(evaluation.R2, 'f', 5, 64),
+	})
+}
+
+// Evaluate predicts every line of a dataset and compares the prediction to the line's one-hot target, to its
+// target values in regression mode, or to its inputs in autoencoder mode
+func (model *Model) Evaluate(dataset Dataset) (Evaluation, error) {
+	switch model.mode {
+	case ModeRegression:
+		return model.evaluateRegression(dataset)
+	case ModeAutoencoder:
+		return model.evaluateRegression(Reconstructing(dataset))
+	case ModeMultiLabel:
+		return model.evaluateMultiLabel(dataset)
+	}
+	var evaluation Evaluation
+	err := dataset.Each(func(line Line) error {
+		if err := model.CheckInput(line.Inputs); err != nil {
+			return err
+		}
+		evaluation.Total++
+		prediction := model.Predict(line.Inputs)
+		var actual string
+		if i := hotIndex(line.Targets); i >= 0 {
+			actual = model.labels[i]
+		}
+		if actual == prediction {
+			evaluation.Correct++
+		}
+		return nil
+	})
+	if err != nil {
+		return Evaluation{}, fmt.Errorf("reading test data: %w", err)
+	}
+	if evaluation.Total > 0 {
+		evaluation.Accuracy = 100 * float64(evaluation.Correct) / float64(evaluation.Total)
+	}
+
+	return evaluation, nil
+}
+
+func (model *Model) evaluateRegression(dataset Dataset) (Evaluation, error) {
+	var evaluation Evaluation
+	outputNum := model.OutputNum()
+	var absolute, squared float64
+	// the sums of each target and its square give the variance that R² compares the squared error to
+	sums := make([]float64, outputNum)
+	sumSquares := make([]float64, outputNum)
+	residuals := make([]float64, outputNum)
+	err := dataset.Each(func(line Line) error {
+		if err := model.CheckInput(line.Inputs); err != nil {
+			return err
+		}
+		evaluation.Total++
+		outputs := model.PredictValues(line.Inputs)
+		targets := model.denormalize(append([]float64(nil), line.Targets...))
+		for i, t := range targets {
+			diff := t - outputs[i]
+			absolute += math.Abs(diff)
+			squared += diff * diff
+			residuals[i] += diff * diff
+			sums[i] += t
+			sumSquares[i] += t * t
+		}
+		return nil
+	})
+	if err != nil {
+		return Evaluation{}, fmt.Errorf("reading test data: %w", err)
+	}
+	if evaluation.Total == 0 {
+		return evaluation, nil
+	}
+	n := float64(evaluation.Total)
+	evaluation.MAE = absolute / (n * float64(outputNum))
+	evaluation.RMSE = math.Sqrt(squared / (n * float64(outputNum)))
+	for i := range sums {
+		total := sumSquares[i] - sums[i]*sums[i]/n
+		if total > 0 {
+			evaluation.R2 += 1 - residuals[i]/total
+		}
+	}
+	evaluation.R2 /= float64(outputNum)
+
+	return evaluation, nil
+}
+
+func (model *Model) evaluateMultiLabel(dataset Dataset) (Evaluation, error) {
+	var evaluation Evaluation
+	outputNum := model.OutputNum()
+	truePositives := make([]int, outputNum)
+	falsePositives := make([]int, outputNum)
+	falseNegatives := make([]int, outputNum)
+	var wrong int
+	err := dataset.Each(func(line Line) error {
+		if err := model.CheckInput(line.Inputs); err != nil {
+			return err
+		}
+		evaluation.Total++
+		outputs := model.outputs(line.Inputs)
+		allRight := true
+		for i, t := range line.Targets {
+			actual := int(t+math.Copysign(0.5, t)) == 1
+			predicted := outputs[i] > model.thresholds[i]
+			switch {
+			case actual && predicted:
+				truePositives[i]++
+			case predicted:
+				falsePositives[i]++
+			case actual:
+				falseNegatives[i]++
+			}
+			if actual != predicted {
+				wrong++
+				allRight = false
+			}
+		}
+		if allRight {
+			evaluation.Correct++
+		}
+		return nil
+	})
+	if err != nil {
+		return Evaluation{}, fmt.Errorf("reading test data: %w", err)
+	}
+	if evaluation.Total == 0 {
+		return evaluation, nil
+	}
+	evaluation.Accuracy = 100 * float64(evaluation.Correct) / float64(evaluation.Total)
+	evaluation.HammingLoss = float64(wrong) / float64(evaluation.Total*outputNum)
+	var tp, fp, fn int
+	for i := 0; i < outputNum; i++ {
+		tp += truePositives[i]
+		fp += falsePositives[i]
+		fn += falseNegatives[i]
+		evaluation.MacroF1 += f1(truePositives[i], falsePositives[i], falseNegatives[i])
+	}
+	evaluation.MacroF1 /= float64(outputNum)
+	evaluation.MicroF1 = f1(tp, fp, fn)
+
+	return evaluation, nil
+}
+
+// f1 is the harmonic mean of precision and recall. A label that was never true or predicted has nothing wrong, so
+// its F1 is 1.
+func f1(truePositives, falsePositives, falseNegatives int) float64 {
+	if truePositives+falsePositives+falseNegatives == 0 {
+		return 1
+	}
+	return 2 * float64(truePositives) / float64(2*truePositives+falsePositives+falseNegatives)
+}
@@ -0,0 +1,183 @@
+package m
+
+import (
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// trainedModel trains a network on a few random lines, so that normalizations have running statistics, and returns
+// its model
+func trainedModel(t *testing.T, c Config, inputNum int) *Model {
+	t.Helper()
+	c.Activator = Sigmoid{}
+	c.LearningRate = 0.1
+	c.TargetLabels = []string{"a", "b", "c"}
+	c.OutputNum = len(c.TargetLabels)
+	c.Seed = 1
+	net := NewNetwork(c)
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 20; i++ {
+		inputs := make([]float64, inputNum)
+		for j := range inputs {
+			inputs[j] = rng.Float64()
+		}
+		targets := make([]float64, c.OutputNum)
+		targets[i%c.OutputNum] = 1
+		if _, err := net.trainOne(inputs, targets); err != nil {
+			t.Fatalf("training: %s", err)
+		}
+	}
+	model, err := net.Model()
+	if err != nil {
+		t.Fatalf("copying model: %s", err)
+	}
+	return model
+}
+
+func TestModelConcurrentPredictions(t *testing.T) {
+	tests := []struct {
+		name string
+		// inputNum is the number of values in each query, which for a sequence is every step's
+		inputNum int
+		config   Config
+	}{
+		{
+			name:     "batch norm and dropout",
+			inputNum: 6,
+			config: Config{
+				InputNum:  6,
+				HiddenNum: 5,
+				LayerNum:  4,
+				Norm:      []string{NormBatch, NormLayer},
+				Dropout:   []float64{0.2, 0.5, 0.5},
+				Bias:      true,
+			},
+		},
+		{
+			name:     "cnn",
+			inputNum: 36,
+			config: Config{
+				InputNum:    36,
+				HiddenNum:   5,
+				LayerNum:    3,
+				CNN:         []ConvSpec{{Kind: LayerConv, Filters: 2, Size: 3, Stride: 1, Padding: 1}, {Kind: LayerMaxPool, Size: 2, Stride: 2}},
+				ImageWidth:  6,
+				ImageHeight: 6,
+				Bias:        true,
+			},
+		},
+		{
+			name:     "recurrent",
+			inputNum: 8,
+			config: Config{
+				InputNum:  2,
+				HiddenNum: 5,
+				LayerNum:  3,
+				RNN:       []RecurrentSpec{{Kind: LayerGRU, Size: 4}, {Kind: LayerLSTM, Size: 3}},
+				Bias:      true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := trainedModel(t, tt.config, tt.inputNum)
+			queries := make([][]float64, 16)
+			expected := make([]Prediction, len(queries))
+			rng := rand.New(rand.NewSource(3))
+			for i := range queries {
+				queries[i] = make([]float64, tt.inputNum)
+				for j := range queries[i] {
+					queries[i][j] = rng.Float64()
+				}
+				expected[i] = model.PredictScores(queries[i])
+			}
+
+			var wg sync.WaitGroup
+			for g := 0; g < 8; g++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for round := 0; round < 10; round++ {
+						for i, query := range queries {
+							prediction := model.PredictScores(query)
+							if prediction.Label != expected[i].Label {
+								t.Errorf("query %d predicted %s, expected %s", i, prediction.Label, expected[i].Label)
+								return
+							}
+							for j, score := range prediction.Scores {
+								if score != expected[i].Scores[j] {
+									t.Errorf("query %d scored %+v, expected %+v", i, score, expected[i].Scores[j])
+									return
+								}
+							}
+						}
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func TestNetworkModelMismatch(t *testing.T) {
+	net := NewNetwork(Config{
+		InputNum:     4,
+		HiddenNum:    3,
+		OutputNum:    2,
+		LayerNum:     3,
+		Activator:    Sigmoid{},
+		TargetLabels: []string{"yes", "no"},
+	})
+	// a network composed without biases has no state for the biases of the layers composed for prediction
+	net.config.Bias = true
+	if _, err := net.Model(); err == nil {
+		t.Fatal("expected an error copying layers that don't match")
+	}
+}
+
+// TestDeprecatedNetworkPredict checks that networks still predict through the deprecated Network API, both after
+// training and when loaded by BestNetworkFor
+func TestDeprecatedNetworkPredict(t *testing.T) {
+	withOutPath(t)
+	net := NewNetwork(Config{
+		Name:         "digits",
+		InputNum:     4,
+		HiddenNum:    3,
+		OutputNum:    2,
+		LayerNum:     3,
+		Epochs:       1,
+		Activator:    Sigmoid{},
+		LearningRate: 0.1,
+		TargetLabels: []string{"0", "1"},
+	})
+	lines := Lines{
+		{Inputs: []float64{1, 0, 0, 1}, Targets: []float64{1, 0}},
+		{Inputs: []float64{0, 1, 1, 0}, Targets: []float64{0, 1}},
+	}
+	if err := net.Train(lines); err != nil {
+		t.Fatalf("training: %s", err)
+	}
+	model, err := net.Model()
+	if err != nil {
+		t.Fatalf("copying model: %s", err)
+	}
+	withAnalysisLog(t, strconv.FormatInt(net.trainingEnd, 10)+"/classification/90/")
+	loaded, err := BestNetworkFor("digits")
+	if err != nil {
+		t.Fatalf("loading: %s", err)
+	}
+	for _, line := range lines {
+		expected := model.Predict(line.Inputs)
+		if got := net.Predict(line.Inputs); got != expected {
+			t.Errorf("trained network predicted %s, its model %s", got, expected)
+		}
+		if got := loaded.Predict(line.Inputs); got != expected {
+			t.Errorf("loaded network predicted %s, the trained model %s", got, expected)
+		}
+	}
+	if _, err := BestNetworkFor("fishing"); err == nil {
+		t.Error("expected an error loading a dataset without runs")
+	}
+}
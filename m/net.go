@@ -2,10 +2,10 @@ package m
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"gonum.org/v1/gonum/mat"
-	"io"
-	"math"
+	"math/rand"
 	"os"
 	"path"
 	"path/filepath"
@@ -24,70 +24,113 @@ type Config struct {
 	TargetLabels []string
 	Activator    Activator
 	LearningRate float64
+	// Observer, if set, is notified at the end of every epoch
+	Observer TrainingObserver
+	// Format describes the layout of the training and test files. Its sizes are taken from InputNum and OutputNum.
+	Format ReadOptions
+	// TestPath is the test data file, which defaults to data/test/<name>.data
+	TestPath string
+	// Seed is the seed the run's randomness was drawn from
+	Seed int64
+	// Transform, if set, is the fitted schema the training data was prepared with. It is saved with the weights so
+	// that raw values can be encoded the same way at prediction time.
+	Transform *Schema
+	// Mode is classification (the default), regression or multilabel
+	Mode string
+	// Loss is mse (the default), huber or bce (the default in multi-label mode), and HuberDelta is where the Huber loss turns from quadratic to linear,
+	// defaulting to 1
+	Loss       string
+	HuberDelta float64
+	// Threshold is the output above which a label is predicted in multi-label mode, defaulting to 0.5, and
+	// Thresholds, if set, gives each label its own threshold instead
+	Threshold  float64
+	Thresholds []float64
+	// Init is the weight initializer, which defaults to the one suited to the activator
+	Init string
+	// Bias adds a bias to every node after the input layer, starting at zero
+	Bias bool
+	// L1 and L2 are the coefficients of the weight penalties applied with every update
+	L1 float64
+	L2 float64
+	// MaxNorm limits the norm of each node's incoming weights. It holds one limit for every layer of weights, or a
+	// limit for each, where 0 leaves a layer unlimited.
+	MaxNorm []float64
+	// Dropout is the fraction of nodes dropped while training, either one rate for every hidden layer or a rate
+	// for the input and each hidden layer. Dropout is drawn from the seed.
+	Dropout []float64
+	// Norm is the normalization of the hidden layers' weighted sums, either one for every hidden layer or one for
+	// each: none, batch or layer
+	Norm []string
+	// ClipValue limits every value of each gradient to ±ClipValue, and ClipNorm scales each line's gradients down
+	// together when their combined norm is over ClipNorm, both before the learning rate scales them. Both are off at 0.
+	ClipValue float64
+	ClipNorm  float64
+	// CNN lists the convolution and pooling layers ahead of the hidden layers, which see each input as channels of
+	// ImageWidth by ImageHeight images
+	CNN         []ConvSpec
+	ImageWidth  int
+	ImageHeight int
+	// RNN lists the recurrent layers ahead of the hidden layers, which read each input as a sequence of steps of
+	// InputNum values
+	RNN []RecurrentSpec
+	// Bottleneck is the number of nodes of the middle hidden layer in autoencoder mode, whose outputs are the
+	// encoding of the inputs
+	Bottleneck int
+	// OnNaN is what training does when an update leaves a weight that isn't finite, abort by default or rollback
+	OnNaN string
 }
 
-func newPredictionNetwork(weights []mat.Matrix, run runInfo) Network {
-	return Network{
-		config: Config{
-			Activator:    run.activator,
-			TargetLabels: run.targetLabels,
-		},
-		weights:      weights,
-		layers:       make([]mat.Matrix, len(weights)+1),
-		weightedSums: make([]mat.Matrix, len(weights)),
-	}
+// Consumers of a run's randomness. Each draws from its own source so that the shuffle order, augmentation, dropout
+// masks and initial weights aren't the same stream.
+const (
+	RandShuffle int64 = iota + 1
+	RandAugment
+	RandDropout
+	RandInit
+)
+
+// Rand returns the source of randomness for one consumer, seeded from the run's seed
+func (c Config) Rand(consumer int64) *rand.Rand {
+	return rand.New(rand.NewSource(c.Seed + consumer))
 }
 
 func NewNetwork(c Config) Network {
-	totalWeights := c.LayerNum - 1
 	net := Network{
-		config:       c,
-		weights:      make([]mat.Matrix, totalWeights),
-		layers:       make([]mat.Matrix, c.LayerNum),
-		weightedSums: make([]mat.Matrix, totalWeights),
-		errors:       make([]mat.Matrix, c.LayerNum),
-	}
-	lastWeightIndex := len(net.weights) - 1
-	for i := 0; i <= lastWeightIndex; i++ {
-		if i == 0 {
-			net.weights[i] = mat.NewDense(
-				net.config.HiddenNum,
-				net.config.InputNum,
-				randomArray(net.config.InputNum*net.config.HiddenNum, float64(net.config.InputNum)),
-			)
-		} else if i == lastWeightIndex {
-			net.weights[i] = mat.NewDense(
-				net.config.OutputNum,
-				net.config.HiddenNum,
-				randomArray(net.config.HiddenNum*net.config.OutputNum, float64(net.config.HiddenNum)),
-			)
-		} else {
-			net.weights[i] = mat.NewDense(
-				net.config.HiddenNum,
-				net.config.HiddenNum,
-				randomArray(net.config.HiddenNum*net.config.HiddenNum, float64(net.config.HiddenNum)),
-			)
-		}
+		config: c,
+		rng:    c.Rand(RandDropout),
 	}
-
+	net.sequential = c.sequential(c.Rand(RandInit), net.rng)
 	return net
 }
 
 type Network struct {
 	trainingStart int64
 	trainingEnd   int64
-	weights       []mat.Matrix
-	layers        []mat.Matrix
-	weightedSums  []mat.Matrix
-	errors        []mat.Matrix
-	config        Config
+	sequential    *Sequential
+	// rng draws the dropout masks
+	rng    *rand.Rand
+	config Config
+	// model is the trained model of a network loaded by BestNetworkFor, which has no layers of its own
+	model *Model
 }
 
-func (net Network) lastIndex() int {
-	return len(net.layers) - 1
+// DataFormat is the layout of the data files with the network's sizes filled in
+func (c Config) DataFormat() ReadOptions {
+	format := c.Format
+	format.InputNum = c.InputNum
+	// an autoencoder's targets are its inputs, so the format keeps its own number of target columns, which are
+	// ignored
+	if c.Mode != ModeAutoencoder {
+		format.OutputNum = c.OutputNum
+	}
+	format.Labels = c.TargetLabels
+	return format
 }
 
 func (net Network) testFilepath() string {
+	if net.config.TestPath != "" {
+		return net.config.TestPath
+	}
 	return path.Join("data", "test", net.config.Name+".data")
 }
 
@@ -99,13 +142,50 @@ func (net Network) testExists() bool {
 	return !info.IsDir()
 }
 
-func (net *Network) Train(lines Lines) error {
+// Train runs every epoch over the dataset, which is read from the start each epoch, and then saves the weights
+func (net *Network) Train(dataset Dataset) error {
 	net.trainingStart = time.Now().Unix()
+	rollback := net.config.OnNaN == OnNaNRollback
+	var good *checkpoint
 	for i := 1; i <= net.config.Epochs; i++ {
-		for _, line := range lines {
-			net.trainOne(line.Inputs, line.Targets)
+		var loss float64
+		var count int
+		err := dataset.Each(func(line Line) error {
+			l, err := net.trainOne(line.Inputs, line.Targets)
+			loss += l
+			count++
+			return err
+		})
+		var nonFinite NonFiniteError
+		if errors.As(err, &nonFinite) {
+			nonFinite.Epoch = i
+			if !rollback || good == nil {
+				return nonFinite
+			}
+			if err := net.restore(good); err != nil {
+				return fmt.Errorf("rolling back to epoch %d: %w", good.epoch, err)
+			}
+			net.config.Epochs = good.epoch
+			fmt.Printf("%s, rolled back to epoch %d\n", nonFinite.Error(), good.epoch)
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading epoch %d: %w", i, err)
+		}
+		if count > 0 {
+			loss /= float64(count)
+		}
+		loss += net.penalty()
+		fmt.Printf("Epoch %d of %d complete, loss %.5f\n", i, net.config.Epochs, loss)
+		if net.config.Observer != nil {
+			err := net.observeEpoch(i, loss)
+			if err != nil {
+				return fmt.Errorf("observing epoch %d: %w", i, err)
+			}
+		}
+		if rollback {
+			good = net.checkpoint(i)
 		}
-		fmt.Printf("Epoch %d of %d complete\n", i, net.config.Epochs)
 	}
 	net.trainingEnd = time.Now().Unix()
 	err := net.save()
@@ -117,61 +197,65 @@ func (net *Network) Train(lines Lines) error {
 	return nil
 }
 
-func (net *Network) trainOne(inputData []float64, targetData []float64) {
-	net.feedForward(inputData)
-	finalOutputs := net.layers[net.lastIndex()]
+// trainOne trains the network on a single line and returns its loss before the update
+func (net *Network) trainOne(inputData []float64, targetData []float64) (float64, error) {
+	finalOutputs := net.sequential.Forward(mat.NewDense(len(inputData), 1, inputData), true)
 
-	net.backpropagate(targetData, finalOutputs)
-}
-
-func (net *Network) backpropagate(targetData []float64, finalOutputs mat.Matrix) {
-	for i := net.lastIndex(); i > 0; i-- {
-		// find errors
-		if i == net.lastIndex() {
-			// network error
-			targets := mat.NewDense(len(targetData), 1, targetData)
-			net.errors[len(net.errors)-1] = subtract(targets, finalOutputs)
-		} else {
-			net.errors[i] = dot(net.weights[i].T(), net.errors[i+1])
-		}
-		net.weights[i-1] = add(net.weights[i-1],
-			scale(net.config.LearningRate,
-				dot(multiply(net.errors[i], net.config.Activator.Deactivate(net.layers[i])),
-					net.layers[i-1].T()))).(*mat.Dense)
+	var loss float64
+	outputErrors := make([]float64, len(targetData))
+	for i, t := range targetData {
+		l, e := net.config.lossOf(t, finalOutputs.At(i, 0))
+		loss += l
+		outputErrors[i] = e
 	}
+	errs := mat.NewDense(len(outputErrors), 1, outputErrors)
+	net.sequential.Backward(Errors{Error: errs, Gradient: errs})
+	err := net.update()
+
+	return loss / float64(len(targetData)), err
 }
 
-func (net *Network) feedForward(inputData []float64) {
-	// first layer
-	net.layers[0] = mat.NewDense(len(inputData), 1, inputData)
-	net.weightedSums[0] = dot(net.weights[0], net.layers[0])
-	for i := range net.layers {
-		if i == 0 {
-			continue
-		}
-		net.layers[i] = apply(net.config.Activator.Activate, net.weightedSums[i-1])
-		// don't get weighted sums if final output
-		if i != len(net.layers)-1 {
-			net.weightedSums[i] = dot(net.weights[i], net.layers[i])
-		}
+// Predict returns the label the network predicts for an input, or its output values in regression and autoencoder
+// mode. It copies the network's weights on every call.
+//
+// Deprecated: Use Model and predict with the Model, which is safe for concurrent use.
+func (net Network) Predict(inputData []float64) string {
+	model, err := net.Model()
+	if err != nil {
+		panic(fmt.Sprintf("copying the network for prediction: %s", err))
 	}
+	return model.Predict(inputData)
 }
 
-func (net Network) Predict(inputData []float64) string {
-	// feedforward
-	net.feedForward(inputData)
-
-	bestOutputIndex := 0
-	highest := 0.0
-	outputs := net.layers[net.lastIndex()]
-	for i := 0; i < net.config.OutputNum; i++ {
-		if outputs.At(i, 0) > highest {
-			bestOutputIndex = i
-			highest = outputs.At(i, 0)
-		}
+// Model returns an immutable copy of the network's current weights for inference. It fails if the layers composed for
+// prediction don't match the network's, so that no model is left with only some of its weights.
+func (net Network) Model() (*Model, error) {
+	if net.model != nil {
+		return net.model, nil
+	}
+	model := newModel(net.config.predictor(), net.config.InputNum, net.config.Activator, net.config.TargetLabels)
+	if err := net.sequential.copyTo(model.sequential); err != nil {
+		return nil, fmt.Errorf("copying layers: %w", err)
+	}
+	model.name = net.config.Name
+	model.transform = net.config.Transform
+	model.mode = modeOrDefault(net.config.Mode)
+	model.encoder = net.config.encoder(model.sequential)
+	if model.mode == ModeAutoencoder {
+		model.targetColumns = net.config.Format.OutputNum
 	}
+	if model.mode == ModeMultiLabel {
+		model.thresholds = net.config.thresholds()
+	}
+	if net.trainingEnd != 0 {
+		model.run = strconv.Itoa(int(net.trainingEnd))
+	}
+	return model, nil
+}
 
-	return net.labelFor(bestOutputIndex)
+// predictor composes the network without dropout and with zeroed weights, to be given the state of a trained one
+func (c Config) predictor() *Sequential {
+	return c.sequential(nil, nil)
 }
 
 var outPath = path.Join("data", "out")
@@ -182,6 +266,8 @@ func (net Network) Analyze() error {
 	var needsHeaders bool
 	if _, err := os.Stat(analysisFilepath); os.IsNotExist(err) {
 		needsHeaders = true
+	} else if err := upgradeAnalysisLog(); err != nil {
+		return fmt.Errorf("upgrading analysis log: %w", err)
 	}
 	file, err := os.OpenFile(analysisFilepath,
 		os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
@@ -190,15 +276,13 @@ func (net Network) Analyze() error {
 	}
 	w := csv.NewWriter(file)
 	if needsHeaders {
-		err = w.Write([]string{
-			"Name", "Activator", "Inputs", "Hiddens", "Outputs", "Layers", "Epochs", "Target Labels", "LR", "End Time", "SecondsToTrain", "Accuracy",
-		})
+		err = w.Write(analysisHeaders)
 		if err != nil {
 			return fmt.Errorf("writing csv headers: %w", err)
 		}
 		w.Flush()
 	}
-	record := make([]string, 12)
+	record := make([]string, csvRecords)
 	record[0] = net.config.Name
 	record[1] = net.config.Activator.String()
 	record[2] = strconv.Itoa(net.config.InputNum)
@@ -210,15 +294,28 @@ func (net Network) Analyze() error {
 	record[8] = strconv.FormatFloat(net.config.LearningRate, 'f', 4, 32)
 	record[9] = strconv.Itoa(int(net.trainingEnd))
 	record[10] = strconv.Itoa(int(net.trainingEnd - net.trainingStart))
+	record[11] = "?"
+	record[12] = modeOrDefault(net.config.Mode)
+	record[13] = net.config.settings()
 	if net.testExists() {
-		accuracy, err := net.test()
+		evaluation, err := net.test()
 		if err != nil {
 			return fmt.Errorf("testing network: %w", err)
 		}
-		record[11] = strconv.FormatFloat(accuracy, 'f', 5, 32)
-		fmt.Printf("Accuracy %.2f%%\n", accuracy)
+		switch net.config.Mode {
+		case ModeRegression, ModeAutoencoder:
+			record[14] = evaluation.metrics()
+			fmt.Printf("MAE %.5f, RMSE %.5f, R² %.5f\n", evaluation.MAE, evaluation.RMSE, evaluation.R2)
+		case ModeMultiLabel:
+			record[11] = strconv.FormatFloat(evaluation.Accuracy, 'f', 5, 32)
+			record[14] = evaluation.multiLabelMetrics()
+			fmt.Printf("Subset accuracy %.2f%%, Hamming loss %.5f, micro F1 %.5f, macro F1 %.5f\n",
+				evaluation.Accuracy, evaluation.HammingLoss, evaluation.MicroF1, evaluation.MacroF1)
+		default:
+			record[11] = strconv.FormatFloat(evaluation.Accuracy, 'f', 5, 32)
+			fmt.Printf("Accuracy %.2f%%\n", evaluation.Accuracy)
+		}
 	} else {
-		record[11] = "?"
 		fmt.Printf("Accuracy: (no test file at %s)\n", net.testFilepath())
 	}
 	err = w.Write(record)
@@ -230,36 +327,16 @@ func (net Network) Analyze() error {
 	return nil
 }
 
-func (net Network) test() (float64, error) {
-	var correct float64
-	var total float64
-	file, err := os.Open(net.testFilepath())
-	if err != nil {
-		return 0, fmt.Errorf("opening test file: %w", err)
+func (net Network) test() (Evaluation, error) {
+	testData := FileDataset{
+		Path:   net.testFilepath(),
+		Format: net.config.DataFormat(),
 	}
-	defer file.Close()
-	lines, err := GetLines(file, net.config.InputNum, net.config.OutputNum)
+	model, err := net.Model()
 	if err != nil {
-		return 0, fmt.Errorf("getting lines: %w", err)
-	}
-	total = float64(len(lines))
-	for _, line := range lines {
-		prediction := net.Predict(line.Inputs)
-		var actual string
-		for i, t := range line.Targets {
-			if int(t+math.Copysign(0.5, t)) == 1 {
-				actual = net.labelFor(i)
-				break
-			}
-		}
-		if actual == prediction {
-			correct++
-		}
+		return Evaluation{}, err
 	}
-
-	percent := 100 * (correct / total)
-
-	return percent, nil
+	return model.Evaluate(testData)
 }
 
 func (net Network) labelFor(index int) string {
@@ -268,136 +345,180 @@ func (net Network) labelFor(index int) string {
 
 func (net Network) save() error {
 	fmt.Printf("saving layer weight files for %s, run #%d\n", net.config.Name, net.trainingEnd)
-	for i := 0; i < len(net.weights); i++ {
-		filename := fmt.Sprintf("%s-%d-%d.wgt", net.config.Name, net.trainingEnd, i)
-		f, err := os.Create(path.Join("data", "out", filename))
-		if err != nil {
-			return err
-		}
-		d := net.weights[i].(*mat.Dense)
-		_, err = d.MarshalBinaryTo(f)
-		if err != nil {
-			return fmt.Errorf("marshalling weights: %w\n", err)
-		}
-		err = f.Close()
+	endTime := strconv.Itoa(int(net.trainingEnd))
+	err := net.sequential.save(layerFilePrefix(net.config.Name, endTime))
+	if err != nil {
+		return err
+	}
+	if net.config.Transform != nil {
+		err := WriteSchema(transformFilepath(net.config.Name, endTime), *net.config.Transform)
 		if err != nil {
-			return err
+			return fmt.Errorf("saving transform: %w", err)
 		}
 	}
 
 	return nil
 }
 
-func load(run runInfo) (Network, error) {
-	sep := string(os.PathSeparator)
-	pattern := fmt.Sprintf(".%s%s%s%s-%s-*.wgt", sep, outPath, sep, run.name, run.bestEndingTime)
+// layerFilePrefix starts the name of every file holding the state of a run's layers
+func layerFilePrefix(name, endTime string) string {
+	return path.Join(outPath, fmt.Sprintf("%s-%s-", name, endTime))
+}
+
+func saveMatrix(filename string, d *mat.Dense) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	_, err = d.MarshalBinaryTo(f)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("marshalling %s: %w", filename, err)
+	}
+	return f.Close()
+}
+
+func loadMatrix(filename string) (*mat.Dense, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var d mat.Dense
+	_, err = d.UnmarshalBinaryFrom(f)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshalling %s: %w", filename, err)
+	}
+	return &d, nil
+}
+
+func transformFilepath(name, endTime string) string {
+	return path.Join(outPath, fmt.Sprintf("%s-%s.transform.json", name, endTime))
+}
+
+func load(run runInfo) (*Model, error) {
+	prefix := layerFilePrefix(run.name, run.endTime)
+	pattern := prefix + "*.wgt"
 	matches, err := filepath.Glob(pattern)
 	if err != nil {
-		return Network{}, fmt.Errorf("matching pattern %s: %w", pattern, err)
+		return nil, fmt.Errorf("matching pattern %s: %w", pattern, err)
 	}
-	weights := make([]mat.Dense, len(matches))
-	for _, m := range matches {
-		splits := strings.Split(m, "-")
-		layerString := strings.Split(splits[2], ".")[0]
-		layerIndex, err := strconv.Atoi(layerString)
-		if err != nil {
-			return Network{}, fmt.Errorf("converting layer portion of filename to a number: %w", err)
-		}
-		f, err := os.Open(m)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no weight files match %s", pattern)
+	}
+	config := Config{
+		Activator: run.activator,
+		Mode:      run.mode,
+		Loss:      run.settings["loss"],
+	}
+	config.CNN, err = ParseCNN(run.settings["cnn"])
+	if err != nil {
+		return nil, fmt.Errorf("reading convolutional layers: %w", err)
+	}
+	config.RNN, err = ParseRNN(run.settings["rnn"])
+	if err != nil {
+		return nil, fmt.Errorf("reading recurrent layers: %w", err)
+	}
+	// the sizes of the network are read from its first and last dense weights, which follow those of any
+	// convolutions or recurrent layers
+	ahead := convCount(config.CNN) + len(config.RNN)
+	config.LayerNum = len(matches) - ahead + 1
+	first, err := loadMatrix(fmt.Sprintf("%s%d.wgt", prefix, ahead))
+	if err != nil {
+		return nil, fmt.Errorf("loading weights: %w", err)
+	}
+	last, err := loadMatrix(fmt.Sprintf("%s%d.wgt", prefix, len(matches)-1))
+	if err != nil {
+		return nil, fmt.Errorf("loading weights: %w", err)
+	}
+	config.HiddenNum, config.InputNum = first.Dims()
+	config.OutputNum, _ = last.Dims()
+	if len(config.CNN) > 0 {
+		config.InputNum, err = imageInputs(&config, run.settings["image"], prefix, config.InputNum)
 		if err != nil {
-			return Network{}, fmt.Errorf("opening file for layer %s: %w", layerString, err)
+			return nil, fmt.Errorf("reading image size: %w", err)
 		}
-		weights[layerIndex].Reset()
-		_, err = weights[layerIndex].UnmarshalBinaryFrom(f)
+	}
+	if len(config.RNN) > 0 {
+		// each step of a sequence has a value for every column of the first recurrent layer's weights
+		weights, err := loadMatrix(prefix + "0.wgt")
 		if err != nil {
-			return Network{}, fmt.Errorf("unmarshalling layer %s: %w", layerString, err)
+			return nil, fmt.Errorf("loading weights: %w", err)
 		}
-		err = f.Close()
+		_, config.InputNum = weights.Dims()
+	}
+	// runs trained without biases have no bias files
+	if _, err := os.Stat(prefix + "0.bias"); err == nil {
+		config.Bias = true
+	}
+	config.Norm, err = ParseNorm(run.settings["norm"], config.LayerNum)
+	if err != nil {
+		return nil, fmt.Errorf("reading normalization: %w", err)
+	}
+	if config.Mode == ModeAutoencoder {
+		config.Bottleneck, err = strconv.Atoi(run.settings["bottleneck"])
 		if err != nil {
-			return Network{}, fmt.Errorf("closing file for layer %s: %w", layerString, err)
+			return nil, fmt.Errorf("reading bottleneck: %w", err)
 		}
 	}
-	matrices := make([]mat.Matrix, len(weights))
-	for i := range weights {
-		matrices[i] = &weights[i]
-	}
-
-	return newPredictionNetwork(matrices, run), nil
-}
 
-type runInfo struct {
-	name           string
-	bestEndingTime string
-	targetLabels   []string
-	activator      Activator
-}
-
-const csvRecords = 12
-
-// bestRun takes a dataset name and returns the best run epoch and activator
-func bestRun(name string) (runInfo, error) {
-	file, err := os.Open(analysisFilepath)
+	model := newModel(config.predictor(), config.InputNum, run.activator, run.targetLabels)
+	err = model.sequential.load(prefix)
 	if err != nil {
-		return runInfo{}, fmt.Errorf("opening analysis csv file: %w", err)
-	}
-	r := csv.NewReader(file)
-	// set to negative because if all accuracies for this data set were not measured, they failed parse of accuracy will
-	// parse as the zero value of a float (0), which allows us to use the untested run until we get test data
-	highestAccuracy := -1.
-	var run runInfo
-	i := 0
-	// Iterate through the records
-	for {
-		// Read each record from csv
-		record, err := r.Read()
-		if err == io.EOF {
-			break
-		}
+		return nil, fmt.Errorf("loading layers: %w", err)
+	}
+	model.name = run.name
+	model.run = run.endTime
+	model.mode = run.mode
+	model.encoder = config.encoder(model.sequential)
+	// runs from before the number of target columns was recorded have to be given it
+	if targets, ok := run.settings["targets"]; ok && model.mode == ModeAutoencoder {
+		model.targetColumns, err = strconv.Atoi(targets)
 		if err != nil {
-			return runInfo{}, fmt.Errorf("reading record: %w", err)
+			return nil, fmt.Errorf("reading target columns: %w", err)
 		}
-		if len(record) != csvRecords {
-			if i == 0 {
-				return runInfo{}, fmt.Errorf("there are %d analysis csv headers, expected %d", len(record), csvRecords)
-			} else {
-				return runInfo{}, fmt.Errorf("there are %d analysis csv values in record %d, expected %d", len(record), i, csvRecords)
-			}
-		}
-		// record[0] is name
-		// record[1] is activator
-		// record[7] is the comma separated list of target labels
-		// record[9] is time ending (epoch time)
-		// record[11] is Accuracy
-		if record[0] != name {
-			continue
+	}
+	if model.mode == ModeMultiLabel {
+		model.thresholds, err = ParseThresholds(run.settings["threshold"], model.OutputNum())
+		if err != nil {
+			return nil, fmt.Errorf("reading thresholds: %w", err)
 		}
-		accuracy, _ := strconv.ParseFloat(record[11], 64)
-		if accuracy > highestAccuracy {
-			run.name = name
-			highestAccuracy = accuracy
-			run.bestEndingTime = record[9]
-			var ok bool
-			run.activator, ok = ActivatorLookup[record[1]]
-			if !ok {
-				return runInfo{}, fmt.Errorf("invalid activator: %s", record[1])
-			}
-			run.targetLabels = strings.Split(record[7], ",")
+	}
+	transformFilename := transformFilepath(run.name, run.endTime)
+	if _, err := os.Stat(transformFilename); err == nil {
+		transform, err := ReadSchema(transformFilename)
+		if err != nil {
+			return nil, fmt.Errorf("loading transform: %w", err)
 		}
-		i++
+		model.transform = &transform
 	}
 
-	return run, nil
+	return model, nil
 }
 
-func BestNetworkFor(name string) (Network, error) {
-	run, err := bestRun(name)
+// imageInputs sets the image size of a convolutional network from the settings of its run and returns its number of
+// inputs. The channels of the images are found from the weights of the first convolution, or from the inputs of the
+// first dense layer when there are only pools.
+func imageInputs(config *Config, image, prefix string, denseInputs int) (int, error) {
+	_, err := fmt.Sscanf(image, "%dx%d", &config.ImageWidth, &config.ImageHeight)
 	if err != nil {
-		return Network{}, fmt.Errorf("getting best epoch for %s: %w", name, err)
+		return 0, fmt.Errorf("parsing %s: %w", image, err)
 	}
-	net, err := load(run)
+	pixels := config.ImageWidth * config.ImageHeight
+	for _, spec := range config.CNN {
+		if spec.Kind != LayerConv {
+			continue
+		}
+		weights, err := loadMatrix(prefix + "0.wgt")
+		if err != nil {
+			return 0, err
+		}
+		_, cols := weights.Dims()
+		return cols / (spec.Size * spec.Size) * pixels, nil
+	}
+	shapes, err := imageShapes(config.CNN, pixels, config.ImageWidth, config.ImageHeight)
 	if err != nil {
-		return Network{}, fmt.Errorf("loading network")
+		return 0, err
 	}
-
-	return net, nil
+	return denseInputs / shapes[len(shapes)-1].Size() * pixels, nil
 }
@@ -0,0 +1,215 @@
+package m
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/mat"
+	"math"
+	"strings"
+)
+
+// Normalizations of a hidden layer's weighted sums before they're activated
+const (
+	NormNone = "none"
+	// NormBatch normalizes each node by the running mean and variance of its weighted sums. Training updates one
+	// line at a time, so the running statistics, updated from every line, stand in for the statistics of a batch and
+	// are the same ones used for prediction.
+	NormBatch = "batch"
+	// NormLayer normalizes the weighted sums of a layer by their own mean and variance, line by line
+	NormLayer = "layer"
+)
+
+const (
+	normEpsilon = 1e-5
+	// batchNormMomentum is how much each line moves the running statistics of batch normalization
+	batchNormMomentum = 0.01
+)
+
+// Normalization is a layer that normalizes a layer's weighted sums and then scales and shifts them by a learned gain
+// and bias per node
+type Normalization struct {
+	kind     string
+	gamma    Parameter
+	beta     Parameter
+	mean     []float64
+	variance []float64
+	// normalized and std are kept from the last forward pass while training
+	normalized []float64
+	std        []float64
+}
+
+// NewNormalization returns a batch or layer normalization of size nodes
+func NewNormalization(kind string, size int) *Normalization {
+	n := &Normalization{
+		kind:     kind,
+		gamma:    Parameter{Value: mat.NewDense(size, 1, nil)},
+		beta:     Parameter{Value: mat.NewDense(size, 1, nil)},
+		mean:     make([]float64, size),
+		variance: make([]float64, size),
+	}
+	for i := range n.mean {
+		n.gamma.Value.Set(i, 0, 1)
+		n.variance[i] = 1
+	}
+	return n
+}
+
+// statistics returns the mean and standard deviation each weighted sum is normalized by
+func (n *Normalization) statistics(sums []float64, mean, std []float64) {
+	if n.kind == NormBatch {
+		for i := range sums {
+			mean[i] = n.mean[i]
+			std[i] = math.Sqrt(n.variance[i] + normEpsilon)
+		}
+		return
+	}
+	var sum float64
+	for _, s := range sums {
+		sum += s
+	}
+	m := sum / float64(len(sums))
+	var squares float64
+	for _, s := range sums {
+		squares += (s - m) * (s - m)
+	}
+	sd := math.Sqrt(squares/float64(len(sums)) + normEpsilon)
+	for i := range sums {
+		mean[i] = m
+		std[i] = sd
+	}
+}
+
+// Forward normalizes the weighted sums. While training, it updates the running statistics of batch normalization.
+func (n *Normalization) Forward(inputs mat.Matrix, training bool) mat.Matrix {
+	rows, _ := inputs.Dims()
+	values := make([]float64, rows)
+	for i := range values {
+		values[i] = inputs.At(i, 0)
+	}
+	if training && n.kind == NormBatch {
+		for i, v := range values {
+			diff := v - n.mean[i]
+			n.mean[i] += batchNormMomentum * diff
+			n.variance[i] = (1-batchNormMomentum)*n.variance[i] + batchNormMomentum*diff*diff
+		}
+	}
+	mean := make([]float64, rows)
+	std := make([]float64, rows)
+	n.statistics(values, mean, std)
+	normalized := make([]float64, rows)
+	out := make([]float64, rows)
+	for i, v := range values {
+		normalized[i] = (v - mean[i]) / std[i]
+		out[i] = n.gamma.Value.At(i, 0)*normalized[i] + n.beta.Value.At(i, 0)
+	}
+	if training {
+		n.normalized = normalized
+		n.std = std
+	}
+	return mat.NewDense(rows, 1, out)
+}
+
+// Backward finds the gradients of the gain and bias and carries both errors back to the weighted sums
+func (n *Normalization) Backward(errors Errors) Errors {
+	size := len(n.normalized)
+	n.gamma.Gradient = mat.NewDense(size, 1, nil)
+	for i := 0; i < size; i++ {
+		n.gamma.Gradient.Set(i, 0, errors.Gradient.At(i, 0)*n.normalized[i])
+	}
+	n.beta.Gradient = mat.DenseCopyOf(errors.Gradient)
+	return Errors{Error: n.backward(errors.Error), Gradient: n.backward(errors.Gradient)}
+}
+
+// backward carries an error on the normalized output back to the weighted sums. Batch normalization treats its
+// running statistics as constants, while layer normalization includes how every sum moves the layer's statistics.
+func (n *Normalization) backward(errors mat.Matrix) mat.Matrix {
+	size := len(n.normalized)
+	scaled := make([]float64, size)
+	for i := range scaled {
+		scaled[i] = errors.At(i, 0) * n.gamma.Value.At(i, 0)
+	}
+	out := make([]float64, size)
+	if n.kind == NormBatch {
+		for i := range out {
+			out[i] = scaled[i] / n.std[i]
+		}
+		return mat.NewDense(size, 1, out)
+	}
+	var sum, dot float64
+	for i, s := range scaled {
+		sum += s
+		dot += s * n.normalized[i]
+	}
+	count := float64(size)
+	for i, s := range scaled {
+		out[i] = (count*s - sum - n.normalized[i]*dot) / (count * n.std[i])
+	}
+	return mat.NewDense(size, 1, out)
+}
+
+// Parameters are the gain and then the bias
+func (n *Normalization) Parameters() []*Parameter {
+	return []*Parameter{&n.gamma, &n.beta}
+}
+
+// State holds the gain, bias, running mean and running variance as rows, saved as .norm
+func (n *Normalization) State() map[string]*mat.Dense {
+	size := len(n.mean)
+	d := mat.NewDense(4, size, nil)
+	d.SetRow(0, mat.Col(nil, 0, n.gamma.Value))
+	d.SetRow(1, mat.Col(nil, 0, n.beta.Value))
+	d.SetRow(2, n.mean)
+	d.SetRow(3, n.variance)
+	return map[string]*mat.Dense{"norm": d}
+}
+
+// SetState restores the gain, bias, running mean and running variance
+func (n *Normalization) SetState(state map[string]*mat.Dense) error {
+	d, ok := state["norm"]
+	if !ok {
+		return fmt.Errorf("missing normalization")
+	}
+	rows, size := d.Dims()
+	if rows != 4 {
+		return fmt.Errorf("expected 4 rows of normalization parameters, got %d", rows)
+	}
+	n.gamma.Value = mat.NewDense(size, 1, mat.Row(nil, 0, d))
+	n.beta.Value = mat.NewDense(size, 1, mat.Row(nil, 1, d))
+	n.mean = mat.Row(nil, 2, d)
+	n.variance = mat.Row(nil, 3, d)
+	return nil
+}
+
+func (n *Normalization) String() string {
+	return n.kind + " norm"
+}
+
+// normFor is the normalization of layer i. Only hidden layers are normalized.
+func (c Config) normFor(i int) string {
+	if i <= 0 || i >= c.LayerNum-1 || len(c.Norm) == 0 {
+		return NormNone
+	}
+	if len(c.Norm) == 1 {
+		return c.Norm[0]
+	}
+	return c.Norm[i-1]
+}
+
+// ParseNorm reads a single normalization for every hidden layer or a comma separated normalization for each
+func ParseNorm(s string, layerNum int) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	norms := strings.Split(s, ",")
+	if len(norms) != 1 && len(norms) != layerNum-2 {
+		return nil, fmt.Errorf("expected 1 normalization or %d for the hidden layers, got %d", layerNum-2, len(norms))
+	}
+	for i, norm := range norms {
+		norms[i] = strings.TrimSpace(norm)
+		switch norms[i] {
+		case NormNone, NormBatch, NormLayer:
+		default:
+			return nil, fmt.Errorf("invalid normalization %s", norm)
+		}
+	}
+	return norms, nil
+}
@@ -0,0 +1,82 @@
+package m
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// checkGradients compares the gradients Backward finds for a layer's parameters and inputs with finite differences
+// of a loss that is a random weighting of the layer's outputs, so that the weighting is the gradient Backward is
+// given
+func checkGradients(t *testing.T, layer Layer, inputs *mat.Dense) {
+	t.Helper()
+	const h = 1e-6
+	rng := rand.New(rand.NewSource(1))
+	outputs := layer.Forward(inputs, true)
+	rows, _ := outputs.Dims()
+	weights := mat.NewDense(rows, 1, nil)
+	for i := 0; i < rows; i++ {
+		weights.Set(i, 0, rng.NormFloat64())
+	}
+	errors := layer.Backward(Errors{Error: weights, Gradient: weights})
+	loss := func() float64 {
+		return mat.Dot(weights.ColView(0), mat.NewVecDense(rows, mat.Col(nil, 0, layer.Forward(inputs, false))))
+	}
+	numeric := func(m *mat.Dense, i, j int) float64 {
+		v := m.At(i, j)
+		m.Set(i, j, v+h)
+		up := loss()
+		m.Set(i, j, v-h)
+		down := loss()
+		m.Set(i, j, v)
+		return (up - down) / (2 * h)
+	}
+	close := func(a, b float64) bool {
+		return math.Abs(a-b) <= 1e-6*math.Max(1, math.Abs(a)+math.Abs(b))
+	}
+	for k, p := range layer.Parameters() {
+		r, c := p.Value.Dims()
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				if expected := numeric(p.Value, i, j); !close(p.Gradient.At(i, j), expected) {
+					t.Errorf("parameter %d at %d,%d: got gradient %g, expected %g", k, i, j, p.Gradient.At(i, j),
+						expected)
+				}
+			}
+		}
+	}
+	r, _ := inputs.Dims()
+	for i := 0; i < r; i++ {
+		expected := numeric(inputs, i, 0)
+		if !close(errors.Gradient.At(i, 0), expected) {
+			t.Errorf("input %d: got gradient %g, expected %g", i, errors.Gradient.At(i, 0), expected)
+		}
+		if !close(errors.Error.At(i, 0), expected) {
+			t.Errorf("input %d: got error %g, expected %g", i, errors.Error.At(i, 0), expected)
+		}
+	}
+}
+
+// randomDense is a matrix of normally distributed values
+func randomDense(rng *rand.Rand, rows, cols int) *mat.Dense {
+	values := make([]float64, rows*cols)
+	for i := range values {
+		values[i] = rng.NormFloat64()
+	}
+	return mat.NewDense(rows, cols, values)
+}
+
+func TestNormalizationGradients(t *testing.T) {
+	for _, kind := range []string{NormLayer, NormBatch} {
+		t.Run(kind, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(2))
+			n := NewNormalization(kind, 5)
+			n.gamma.Value = randomDense(rng, 5, 1)
+			n.beta.Value = randomDense(rng, 5, 1)
+			checkGradients(t, n, randomDense(rng, 5, 1))
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package m
+
+import "fmt"
+
+// EpochStats summarizes a finished training epoch
+type EpochStats struct {
+	Name         string
+	Epoch        int
+	Epochs       int
+	Loss         float64
+	LearningRate float64
+	// ValidationAccuracy is the percent of the test set predicted correctly and is only set when Validated
+	ValidationAccuracy float64
+	Validated          bool
+}
+
+// TrainingObserver is notified as training progresses, such as to export metrics
+type TrainingObserver interface {
+	ObserveEpoch(stats EpochStats)
+}
+
+func (net *Network) observeEpoch(epoch int, loss float64) error {
+	stats := EpochStats{
+		Name:         net.config.Name,
+		Epoch:        epoch,
+		Epochs:       net.config.Epochs,
+		Loss:         loss,
+		LearningRate: net.config.LearningRate,
+	}
+	if net.testExists() && net.config.Mode != ModeRegression && net.config.Mode != ModeAutoencoder {
+		evaluation, err := net.test()
+		if err != nil {
+			return fmt.Errorf("validating: %w", err)
+		}
+		stats.ValidationAccuracy = evaluation.Accuracy
+		stats.Validated = true
+	}
+	net.config.Observer.ObserveEpoch(stats)
+
+	return nil
+}
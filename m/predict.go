@@ -0,0 +1,132 @@
+package m
+
+import (
+	"sort"
+	"strings"
+)
+
+// Score is the output of a single output node along with the label it represents. Probability is only
+// meaningful when the owning Prediction is Calibrated.
+type Score struct {
+	Label       string  `json:"label"`
+	Output      float64 `json:"output"`
+	Probability float64 `json:"probability"`
+}
+
+// Prediction holds every target label with its score, ordered from most to least likely.
+type Prediction struct {
+	Label      string  `json:"label"`
+	Scores     []Score `json:"scores"`
+	Calibrated bool    `json:"calibrated"`
+	// Values are the outputs in label order, and are only set in regression mode
+	Values []float64 `json:"values,omitempty"`
+	// Labels are every label above its threshold, and are only set in multi-label mode
+	Labels []string `json:"labels,omitempty"`
+}
+
+// Top returns the k highest scoring labels. A k of zero or less returns every label, as does a regression, whose
+// outputs aren't ranked. A multi-label prediction also keeps every predicted label, since each label's threshold can
+// differ and more labels can be predicted than k.
+func (p Prediction) Top(k int) []Score {
+	if k <= 0 || k > len(p.Scores) || p.Values != nil {
+		return p.Scores
+	}
+	if p.Labels == nil {
+		return p.Scores[:k]
+	}
+	predicted := make(map[string]bool, len(p.Labels))
+	for _, label := range p.Labels {
+		predicted[label] = true
+	}
+	var top []Score
+	for i, score := range p.Scores {
+		if i < k || predicted[score.Label] {
+			top = append(top, score)
+		}
+	}
+	return top
+}
+
+// Calibrator is implemented by output activators whose outputs can be read as a probability distribution over the
+// target labels, which only softmax's are. Independent sigmoid outputs divided by their sum are not calibrated.
+type Calibrator interface {
+	Probabilities(outputs []float64) []float64
+}
+
+func isCalibrator(activator Activator) bool {
+	_, ok := activator.(Calibrator)
+	return ok
+}
+
+// newPrediction ranks the labels by their outputs, which are read as probabilities by calibrator, or are left
+// uncalibrated when it is nil
+func newPrediction(outputs []float64, labels []string, calibrator Calibrator) Prediction {
+	scores := make([]Score, len(outputs))
+	for i, o := range outputs {
+		scores[i] = Score{
+			Label:  labels[i],
+			Output: o,
+		}
+	}
+	calibrated := calibrator != nil
+	if calibrated {
+		for i, p := range calibrator.Probabilities(outputs) {
+			scores[i].Probability = p
+		}
+	}
+	// a stable sort keeps the lowest index first when outputs tie
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].Output > scores[j].Output
+	})
+
+	return Prediction{
+		Label:      scores[0].Label,
+		Scores:     scores,
+		Calibrated: calibrated,
+	}
+}
+
+// newRegressionPrediction keeps the outputs in label order since there is no most likely label to rank by
+func newRegressionPrediction(values []float64, labels []string) Prediction {
+	scores := make([]Score, len(values))
+	for i, v := range values {
+		scores[i] = Score{
+			Label:  labels[i],
+			Output: v,
+		}
+	}
+	return Prediction{
+		Scores: scores,
+		Values: values,
+	}
+}
+
+// newMultiLabelPrediction ranks the independent sigmoid outputs and predicts every label whose output is above its
+// threshold. Each output is the probability of its label when calibrated, which is when it was trained with binary
+// cross-entropy.
+func newMultiLabelPrediction(outputs []float64, labels []string, thresholds []float64, calibrated bool) Prediction {
+	scores := make([]Score, len(outputs))
+	predicted := make([]string, 0, len(outputs))
+	for i, o := range outputs {
+		scores[i] = Score{
+			Label:  labels[i],
+			Output: o,
+		}
+		if calibrated {
+			scores[i].Probability = o
+		}
+		if o > thresholds[i] {
+			predicted = append(predicted, labels[i])
+		}
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].Output > scores[j].Output
+	})
+
+	return Prediction{
+		Label:      strings.Join(predicted, ","),
+		Scores:     scores,
+		Calibrated: calibrated,
+		Labels:     predicted,
+	}
+}
@@ -0,0 +1,123 @@
+package m
+
+import (
+	"reflect"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestPredictionTop(t *testing.T) {
+	labels := []string{"a", "b", "c", "d"}
+	outputs := []float64{0.7, 0.2, 0.9, 0.6}
+	tests := []struct {
+		name       string
+		prediction Prediction
+		k          int
+		expected   []string
+	}{
+		{"classification", newPrediction(outputs, labels, nil), 2, []string{"c", "a"}},
+		{"every label", newPrediction(outputs, labels, nil), 0, []string{"c", "a", "d", "b"}},
+		{"more than every label", newPrediction(outputs, labels, nil), 5, []string{"c", "a", "d", "b"}},
+		{
+			name:       "multi-label keeps predicted labels",
+			prediction: newMultiLabelPrediction(outputs, labels, []float64{0.5, 0.5, 0.5, 0.5}, true),
+			k:          1,
+			expected:   []string{"c", "a", "d"},
+		},
+		{
+			name:       "multi-label keeps predicted labels below the top",
+			prediction: newMultiLabelPrediction(outputs, labels, []float64{0.95, 0.1, 0.95, 0.1}, true),
+			k:          1,
+			expected:   []string{"c", "d", "b"},
+		},
+		{
+			name:       "multi-label without predicted labels",
+			prediction: newMultiLabelPrediction(outputs, labels, []float64{0.95, 0.95, 0.95, 0.95}, true),
+			k:          2,
+			expected:   []string{"c", "a"},
+		},
+		{"regression", newRegressionPrediction(outputs, labels), 1, []string{"a", "b", "c", "d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, score := range tt.prediction.Top(tt.k) {
+				got = append(got, score.Label)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("got %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPredictionCalibration(t *testing.T) {
+	outputs := []float64{0.2, 0.6}
+	labels := []string{"yes", "no"}
+	if p := newPrediction(outputs, labels, nil); p.Calibrated || p.Scores[0].Probability != 0 {
+		t.Errorf("uncalibrated outputs were reported as probabilities: %+v", p)
+	}
+	p := newPrediction([]float64{0.25, 0.75}, labels, Softmax{})
+	if !p.Calibrated || p.Scores[0].Probability != 0.75 || p.Scores[1].Probability != 0.25 {
+		t.Errorf("softmax outputs weren't reported as probabilities: %+v", p)
+	}
+	if p := newMultiLabelPrediction(outputs, labels, []float64{0.5, 0.5}, false); p.Calibrated {
+		t.Errorf("multi-label outputs not trained with cross-entropy were reported as probabilities: %+v", p)
+	}
+}
+
+// member is a model of two inputs passed straight to its output activation, trained with cross-entropy when
+// lossDerivative is set
+func member(mode string, activator Activator, lossDerivative bool) *Model {
+	activation := NewActivation(activator)
+	activation.lossDerivative = lossDerivative
+	sequential := &Sequential{Layers: []Layer{NewDense(mat.NewDense(2, 2, []float64{1, 0, 0, 1}), nil, 0, 0, 0),
+		activation}}
+	model := newModel(sequential, 2, activator, []string{"yes", "no"})
+	model.mode = mode
+	model.thresholds = []float64{0.5, 0.5}
+	return model
+}
+
+func TestEnsembleCalibration(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        string
+		members     []*Model
+		combination string
+		calibrated  bool
+	}{
+		{"softmax average", ModeClassification, []*Model{member(ModeClassification, Softmax{}, true),
+			member(ModeClassification, Softmax{}, true)}, CombineAverage, true},
+		{"softmax vote", ModeClassification, []*Model{member(ModeClassification, Softmax{}, true),
+			member(ModeClassification, Softmax{}, true)}, CombineVote, false},
+		{"softmax weighted vote", ModeClassification, []*Model{member(ModeClassification, Softmax{}, true),
+			member(ModeClassification, Softmax{}, true)}, CombineWeighted, false},
+		{"softmax and sigmoid average", ModeClassification, []*Model{member(ModeClassification, Softmax{}, true),
+			member(ModeClassification, Sigmoid{}, false)}, CombineAverage, false},
+		{"multi-label average", ModeMultiLabel, []*Model{member(ModeMultiLabel, Sigmoid{}, true),
+			member(ModeMultiLabel, Sigmoid{}, true)}, CombineAverage, true},
+		{"multi-label vote", ModeMultiLabel, []*Model{member(ModeMultiLabel, Sigmoid{}, true),
+			member(ModeMultiLabel, Sigmoid{}, true)}, CombineVote, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ensemble := &Model{
+				mode:        tt.mode,
+				labels:      []string{"yes", "no"},
+				thresholds:  []float64{0.5, 0.5},
+				members:     tt.members,
+				weights:     []float64{1, 1},
+				combination: tt.combination,
+			}
+			p := ensemble.PredictScores([]float64{2, 1})
+			if p.Calibrated != tt.calibrated {
+				t.Errorf("got calibrated %t, expected %t: %+v", p.Calibrated, tt.calibrated, p)
+			}
+			if !tt.calibrated && p.Scores[0].Probability != 0 {
+				t.Errorf("uncalibrated scores have probabilities: %+v", p)
+			}
+		})
+	}
+}
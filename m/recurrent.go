@@ -0,0 +1,383 @@
+package m
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/mat"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Kinds of recurrent layer
+const (
+	// LayerRNN is a simple recurrent layer, whose state is the tanh of its weighted input and previous state
+	LayerRNN = "rnn"
+	// LayerGRU is a gated recurrent unit, with update and reset gates
+	LayerGRU = "gru"
+	// LayerLSTM is a long short-term memory layer, with input, forget and output gates and a cell state
+	LayerLSTM = "lstm"
+)
+
+// gates is the number of weighted sums each kind of layer finds for every node
+var gates = map[string]int{LayerRNN: 1, LayerGRU: 3, LayerLSTM: 4}
+
+// Recurrent reads its input as a sequence of steps of the same number of values, one after another, and carries a
+// state of one value per node from each step to the next. It outputs the state after the last step, or every step's
+// state when it feeds another recurrent layer. Training is backpropagation through time: the gradient of the output
+// is carried back through every step, so unlike the dense layers both errors it passes back are that gradient.
+type Recurrent struct {
+	kind string
+	size int
+	// sequences outputs the state of every step instead of only the last
+	sequences bool
+	// weights multiply the step's input and recurrent its previous state, with the rows of each gate one after
+	// another: update, reset and candidate for a GRU, and input, forget, candidate and output for an LSTM
+	weights   Parameter
+	recurrent Parameter
+	// biases is nil for a layer without biases
+	biases *Parameter
+	steps  []recurrentStep
+}
+
+// recurrentStep is what Backward needs from a step of the last training Forward
+type recurrentStep struct {
+	inputs   *mat.VecDense
+	previous *mat.VecDense
+	// gates are the activations of the gates, and previousCell and cell are the LSTM's cell state before and after
+	// the step
+	gates        *mat.VecDense
+	previousCell *mat.VecDense
+	cell         *mat.VecDense
+	state        *mat.VecDense
+}
+
+// NewRecurrent returns a recurrent layer of a kind with a node for every row of recurrent. weights have a column
+// for each value of a step and recurrent a column for each node, and both have a row for each gate of each node.
+// biases may be nil. The weights are regularized by the L1 and L2 coefficients.
+func NewRecurrent(kind string, weights, recurrent, biases *mat.Dense, sequences bool, l1, l2 float64) *Recurrent {
+	_, size := recurrent.Dims()
+	r := &Recurrent{
+		kind:      kind,
+		size:      size,
+		sequences: sequences,
+		weights:   Parameter{Value: weights, L1: l1, L2: l2},
+		recurrent: Parameter{Value: recurrent, L1: l1, L2: l2},
+	}
+	if biases != nil {
+		r.biases = &Parameter{Value: biases}
+	}
+	return r
+}
+
+// Forward runs every step of the sequence
+func (r *Recurrent) Forward(inputs mat.Matrix, training bool) mat.Matrix {
+	_, in := r.weights.Value.Dims()
+	length, _ := inputs.Dims()
+	steps := length / in
+	if training {
+		r.steps = make([]recurrentStep, steps)
+	}
+	state := mat.NewVecDense(r.size, nil)
+	cell := mat.NewVecDense(r.size, nil)
+	var outputs []float64
+	for t := 0; t < steps; t++ {
+		step := recurrentStep{
+			inputs:       mat.NewVecDense(in, nil),
+			previous:     state,
+			previousCell: cell,
+		}
+		for i := 0; i < in; i++ {
+			step.inputs.SetVec(i, inputs.At(t*in+i, 0))
+		}
+		r.step(&step)
+		state, cell = step.state, step.cell
+		if training {
+			r.steps[t] = step
+		}
+		if r.sequences || t == steps-1 {
+			outputs = append(outputs, state.RawVector().Data...)
+		}
+	}
+	if outputs == nil {
+		return mat.NewDense(r.size, 1, nil)
+	}
+	return mat.NewDense(len(outputs), 1, outputs)
+}
+
+// step finds the gates and new state of one step from its inputs and previous state
+func (r *Recurrent) step(s *recurrentStep) {
+	n := r.size
+	sums := mat.NewVecDense(gates[r.kind]*n, nil)
+	sums.MulVec(r.weights.Value, s.inputs)
+	if r.biases != nil {
+		sums.AddVec(sums, r.biases.Value.ColView(0))
+	}
+	s.gates = mat.NewVecDense(gates[r.kind]*n, nil)
+	s.state = mat.NewVecDense(n, nil)
+	s.cell = s.previousCell
+	switch r.kind {
+	case LayerRNN:
+		recurrent := mat.NewVecDense(n, nil)
+		recurrent.MulVec(r.recurrent.Value, s.previous)
+		for i := 0; i < n; i++ {
+			s.gates.SetVec(i, math.Tanh(sums.AtVec(i)+recurrent.AtVec(i)))
+			s.state.SetVec(i, s.gates.AtVec(i))
+		}
+	case LayerGRU:
+		// the update and reset gates see the previous state, and the candidate sees it scaled by the reset gate
+		recurrent := mat.NewVecDense(2*n, nil)
+		recurrent.MulVec(r.recurrent.Value.Slice(0, 2*n, 0, n), s.previous)
+		for i := 0; i < 2*n; i++ {
+			s.gates.SetVec(i, sigmoid(sums.AtVec(i)+recurrent.AtVec(i)))
+		}
+		reset := mat.NewVecDense(n, nil)
+		reset.MulElemVec(s.gates.SliceVec(n, 2*n), s.previous)
+		candidate := mat.NewVecDense(n, nil)
+		candidate.MulVec(r.recurrent.Value.Slice(2*n, 3*n, 0, n), reset)
+		for i := 0; i < n; i++ {
+			c := math.Tanh(sums.AtVec(2*n+i) + candidate.AtVec(i))
+			s.gates.SetVec(2*n+i, c)
+			z := s.gates.AtVec(i)
+			s.state.SetVec(i, (1-z)*c+z*s.previous.AtVec(i))
+		}
+	case LayerLSTM:
+		recurrent := mat.NewVecDense(4*n, nil)
+		recurrent.MulVec(r.recurrent.Value, s.previous)
+		s.cell = mat.NewVecDense(n, nil)
+		for i := 0; i < 4*n; i++ {
+			sum := sums.AtVec(i) + recurrent.AtVec(i)
+			if i/n == 2 {
+				s.gates.SetVec(i, math.Tanh(sum))
+			} else {
+				s.gates.SetVec(i, sigmoid(sum))
+			}
+		}
+		for i := 0; i < n; i++ {
+			input, forget, candidate, output := s.gates.AtVec(i), s.gates.AtVec(n+i), s.gates.AtVec(2*n+i),
+				s.gates.AtVec(3*n+i)
+			c := forget*s.previousCell.AtVec(i) + input*candidate
+			s.cell.SetVec(i, c)
+			s.state.SetVec(i, output*math.Tanh(c))
+		}
+	}
+}
+
+// Backward carries the gradient of the output back through every step, from the last to the first, adding up the
+// gradients of the weights over the steps
+func (r *Recurrent) Backward(errors Errors) Errors {
+	n := r.size
+	rows, in := r.weights.Value.Dims()
+	r.weights.Gradient = mat.NewDense(rows, in, nil)
+	r.recurrent.Gradient = mat.NewDense(rows, n, nil)
+	if r.biases != nil {
+		r.biases.Gradient = mat.NewDense(rows, 1, nil)
+	}
+	outputs := mat.Col(nil, 0, errors.Gradient)
+	inputs := make([]float64, len(r.steps)*in)
+	// state and cell are the gradients of the state and cell carried back from the step after
+	state := mat.NewVecDense(n, nil)
+	cell := mat.NewVecDense(n, nil)
+	for t := len(r.steps) - 1; t >= 0; t-- {
+		s := r.steps[t]
+		if r.sequences {
+			state.AddVec(state, mat.NewVecDense(n, outputs[t*n:(t+1)*n]))
+		} else if t == len(r.steps)-1 {
+			state.AddVec(state, mat.NewVecDense(n, outputs))
+		}
+		// sums is the gradient of each gate's weighted sum and previous that of the previous state
+		sums := mat.NewVecDense(rows, nil)
+		previous := mat.NewVecDense(n, nil)
+		switch r.kind {
+		case LayerRNN:
+			for i := 0; i < n; i++ {
+				h := s.gates.AtVec(i)
+				sums.SetVec(i, state.AtVec(i)*(1-h*h))
+			}
+			previous.MulVec(r.recurrent.Value.T(), sums)
+			accumulate(r.recurrent.Gradient, 0, rows, sums, s.previous)
+		case LayerGRU:
+			for i := 0; i < n; i++ {
+				update, candidate := s.gates.AtVec(i), s.gates.AtVec(2*n+i)
+				g := state.AtVec(i)
+				sums.SetVec(i, g*(s.previous.AtVec(i)-candidate)*update*(1-update))
+				sums.SetVec(2*n+i, g*(1-update)*(1-candidate*candidate))
+				previous.SetVec(i, g*update)
+			}
+			// the candidate's recurrent weights multiplied the previous state scaled by the reset gate
+			scaled := mat.NewVecDense(n, nil)
+			scaled.MulVec(r.recurrent.Value.Slice(2*n, 3*n, 0, n).T(), sums.SliceVec(2*n, 3*n))
+			reset := mat.NewVecDense(n, nil)
+			for i := 0; i < n; i++ {
+				g := s.gates.AtVec(n + i)
+				reset.SetVec(i, g*s.previous.AtVec(i))
+				sums.SetVec(n+i, scaled.AtVec(i)*s.previous.AtVec(i)*g*(1-g))
+				previous.SetVec(i, previous.AtVec(i)+scaled.AtVec(i)*g)
+			}
+			gated := mat.NewVecDense(n, nil)
+			gated.MulVec(r.recurrent.Value.Slice(0, 2*n, 0, n).T(), sums.SliceVec(0, 2*n))
+			previous.AddVec(previous, gated)
+			accumulate(r.recurrent.Gradient, 0, 2*n, sums, s.previous)
+			accumulate(r.recurrent.Gradient, 2*n, 3*n, sums, reset)
+		case LayerLSTM:
+			for i := 0; i < n; i++ {
+				input, forget, candidate, output := s.gates.AtVec(i), s.gates.AtVec(n+i), s.gates.AtVec(2*n+i),
+					s.gates.AtVec(3*n+i)
+				squashed := math.Tanh(s.cell.AtVec(i))
+				c := cell.AtVec(i) + state.AtVec(i)*output*(1-squashed*squashed)
+				sums.SetVec(i, c*candidate*input*(1-input))
+				sums.SetVec(n+i, c*s.previousCell.AtVec(i)*forget*(1-forget))
+				sums.SetVec(2*n+i, c*input*(1-candidate*candidate))
+				sums.SetVec(3*n+i, state.AtVec(i)*squashed*output*(1-output))
+				cell.SetVec(i, c*forget)
+			}
+			previous.MulVec(r.recurrent.Value.T(), sums)
+			accumulate(r.recurrent.Gradient, 0, rows, sums, s.previous)
+		}
+		accumulate(r.weights.Gradient, 0, rows, sums, s.inputs)
+		if r.biases != nil {
+			r.biases.Gradient.Add(r.biases.Gradient, mat.NewDense(rows, 1, sums.RawVector().Data))
+		}
+		step := mat.NewVecDense(in, inputs[t*in:(t+1)*in])
+		step.MulVec(r.weights.Value.T(), sums)
+		state = previous
+	}
+	gradient := mat.NewDense(len(inputs), 1, inputs)
+	return Errors{Error: gradient, Gradient: gradient}
+}
+
+// accumulate adds the product of the gradients of rows from to to of the weighted sums and the values their weights
+// multiplied to the gradient of those weights
+func accumulate(gradient *mat.Dense, from, to int, sums *mat.VecDense, values mat.Vector) {
+	_, cols := gradient.Dims()
+	rows := gradient.Slice(from, to, 0, cols).(*mat.Dense)
+	rows.RankOne(rows, 1, sums.SliceVec(from, to), values)
+}
+
+// Parameters are the weights of the input, then those of the previous state, then the biases
+func (r *Recurrent) Parameters() []*Parameter {
+	if r.biases == nil {
+		return []*Parameter{&r.weights, &r.recurrent}
+	}
+	return []*Parameter{&r.weights, &r.recurrent, r.biases}
+}
+
+// State is the weights of the input, saved as .wgt, those of the previous state, saved as .rec, and the biases,
+// saved as .bias
+func (r *Recurrent) State() map[string]*mat.Dense {
+	state := map[string]*mat.Dense{"wgt": r.weights.Value, "rec": r.recurrent.Value}
+	if r.biases != nil {
+		state["bias"] = r.biases.Value
+	}
+	return state
+}
+
+// SetState restores the weights and biases
+func (r *Recurrent) SetState(state map[string]*mat.Dense) error {
+	weights, ok := state["wgt"]
+	if !ok {
+		return fmt.Errorf("missing weights")
+	}
+	recurrent, ok := state["rec"]
+	if !ok {
+		return fmt.Errorf("missing recurrent weights")
+	}
+	if rows, _ := recurrent.Dims(); rows != gates[r.kind]*r.size {
+		return fmt.Errorf("expected %d rows of recurrent weights, got %d", gates[r.kind]*r.size, rows)
+	}
+	r.weights.Value = weights
+	r.recurrent.Value = recurrent
+	if biases, ok := state["bias"]; ok && r.biases != nil {
+		r.biases.Value = biases
+	}
+	return nil
+}
+
+// Size is the number of values in each step and the number of nodes
+func (r *Recurrent) Size() (inputs, nodes int) {
+	_, inputs = r.weights.Value.Dims()
+	return inputs, r.size
+}
+
+func (r *Recurrent) String() string {
+	inputs, nodes := r.Size()
+	return fmt.Sprintf("%s %dx%d", r.kind, inputs, nodes)
+}
+
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+// RecurrentSpec describes a recurrent layer given on the command line, such as lstm:32
+type RecurrentSpec struct {
+	Kind string
+	Size int
+}
+
+func (s RecurrentSpec) String() string {
+	return fmt.Sprintf("%s:%d", s.Kind, s.Size)
+}
+
+// ParseRNN reads a comma separated list of recurrent layers, such as gru:32 or lstm:32,lstm:16, which are stacked in
+// order ahead of the dense layers
+func ParseRNN(s string) ([]RecurrentSpec, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var specs []RecurrentSpec
+	for _, layer := range strings.Split(s, ",") {
+		layer = strings.TrimSpace(layer)
+		splits := strings.Split(layer, ":")
+		if len(splits) != 2 {
+			return nil, fmt.Errorf("expected a kind and its size, such as lstm:32, got %s", layer)
+		}
+		spec := RecurrentSpec{Kind: splits[0]}
+		if _, ok := gates[spec.Kind]; !ok {
+			return nil, fmt.Errorf("invalid layer %s, expected %s, %s or %s", spec.Kind, LayerRNN, LayerGRU, LayerLSTM)
+		}
+		var err error
+		spec.Size, err = strconv.Atoi(splits[1])
+		if err != nil {
+			return nil, fmt.Errorf("parsing size of %s: %w", layer, err)
+		}
+		if spec.Size < 1 {
+			return nil, fmt.Errorf("size of %s must be positive", layer)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// formatRNN writes layers as ParseRNN reads them
+func formatRNN(specs []RecurrentSpec) string {
+	formatted := make([]string, len(specs))
+	for i, spec := range specs {
+		formatted[i] = spec.String()
+	}
+	return strings.Join(formatted, ",")
+}
+
+// recurrent composes the recurrent layers ahead of the dense layers and returns them with the number of values they
+// pass on. Every layer but the last passes on the state of every step to the next. Weights are drawn from rng by
+// init, and an LSTM's forget gate biases start at 1 so that it remembers from the start of training.
+func (c Config) recurrent(init string, rng *rand.Rand) ([]Layer, int) {
+	var layers []Layer
+	in := c.InputNum
+	for i, spec := range c.RNN {
+		rows := gates[spec.Kind] * spec.Size
+		weights := mat.NewDense(rows, in, initialWeights(init, rng, rows, in))
+		recurrent := mat.NewDense(rows, spec.Size, initialWeights(init, rng, rows, spec.Size))
+		var biases *mat.Dense
+		if c.Bias {
+			biases = mat.NewDense(rows, 1, nil)
+			if spec.Kind == LayerLSTM {
+				for j := spec.Size; j < 2*spec.Size; j++ {
+					biases.Set(j, 0, 1)
+				}
+			}
+		}
+		layers = append(layers, NewRecurrent(spec.Kind, weights, recurrent, biases, i < len(c.RNN)-1, c.L1, c.L2))
+		in = spec.Size
+	}
+	return layers, in
+}
@@ -0,0 +1,60 @@
+package m
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestParseRNN(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected []RecurrentSpec
+	}{
+		{"", nil},
+		{"rnn:8", []RecurrentSpec{{Kind: LayerRNN, Size: 8}}},
+		{"lstm:32, gru:16", []RecurrentSpec{{Kind: LayerLSTM, Size: 32}, {Kind: LayerGRU, Size: 16}}},
+	}
+	for _, tt := range tests {
+		specs, err := ParseRNN(tt.s)
+		if err != nil {
+			t.Errorf("%q: %s", tt.s, err)
+			continue
+		}
+		if !reflect.DeepEqual(specs, tt.expected) {
+			t.Errorf("%q: got %v, expected %v", tt.s, specs, tt.expected)
+		}
+		if tt.s != "" {
+			if again, err := ParseRNN(formatRNN(specs)); err != nil || !reflect.DeepEqual(again, specs) {
+				t.Errorf("%q: formatted as %q, which parses as %v, %v", tt.s, formatRNN(specs), again, err)
+			}
+		}
+	}
+}
+
+func TestParseRNNErrors(t *testing.T) {
+	for _, s := range []string{"lstm", "lstm:", "lstm:x", "lstm:0", "lstm:-1", "conv:8", "gru:8:2", "rnn:4,"} {
+		if specs, err := ParseRNN(s); err == nil {
+			t.Errorf("%q: expected an error, got %v", s, specs)
+		}
+	}
+}
+
+func TestRecurrentGradients(t *testing.T) {
+	const steps, in, nodes = 4, 3, 2
+	for _, kind := range []string{LayerRNN, LayerGRU, LayerLSTM} {
+		for _, sequences := range []bool{false, true} {
+			name := kind
+			if sequences {
+				name += " sequences"
+			}
+			t.Run(name, func(t *testing.T) {
+				rng := rand.New(rand.NewSource(5))
+				rows := gates[kind] * nodes
+				layer := NewRecurrent(kind, randomDense(rng, rows, in), randomDense(rng, rows, nodes),
+					randomDense(rng, rows, 1), sequences, 0, 0)
+				checkGradients(t, layer, randomDense(rng, steps*in, 1))
+			})
+		}
+	}
+}
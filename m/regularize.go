@@ -0,0 +1,98 @@
+package m
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/mat"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// regularize shrinks a parameter towards zero by its L1 and L2 coefficients and then limits the norm of each row,
+// which is a node's incoming weights, to its max-norm
+func regularize(p *Parameter, rate float64) {
+	w := p.Value
+	l1, l2 := p.L1, p.L2
+	if l1 != 0 || l2 != 0 {
+		w.Apply(func(_, _ int, v float64) float64 {
+			// the gradient of the L1 penalty is the sign of the weight, which would make a weight near zero
+			// oscillate, so shrinking stops at zero instead of crossing it
+			shrunk := v - rate*l2*v
+			if l1 != 0 {
+				step := rate * l1
+				if math.Abs(shrunk) <= step {
+					return 0
+				}
+				shrunk -= math.Copysign(step, shrunk)
+			}
+			return shrunk
+		}, w)
+	}
+	limit := p.MaxNorm
+	if limit <= 0 {
+		return
+	}
+	rows, _ := w.Dims()
+	for r := 0; r < rows; r++ {
+		row := w.RawRowView(r)
+		norm := mat.Norm(mat.NewVecDense(len(row), row), 2)
+		if norm > limit {
+			for j := range row {
+				row[j] *= limit / norm
+			}
+		}
+	}
+}
+
+// penalty is the L1 and L2 penalty of the current parameters, which is added to the reported loss
+func (net *Network) penalty() float64 {
+	var penalty float64
+	for _, p := range net.sequential.Parameters() {
+		if p.L1 == 0 && p.L2 == 0 {
+			continue
+		}
+		rows, cols := p.Value.Dims()
+		for r := 0; r < rows; r++ {
+			for c := 0; c < cols; c++ {
+				v := p.Value.At(r, c)
+				penalty += p.L1*math.Abs(v) + p.L2*v*v/2
+			}
+		}
+	}
+	return penalty
+}
+
+// maxNorm is the max-norm of the weights leading into layer i+1, or 0 when they aren't limited
+func (c Config) maxNorm(i int) float64 {
+	switch len(c.MaxNorm) {
+	case 0:
+		return 0
+	case 1:
+		return c.MaxNorm[0]
+	}
+	return c.MaxNorm[i]
+}
+
+// ParseMaxNorm reads a single max-norm for every layer of weights or a comma separated max-norm for each, where 0
+// leaves a layer unlimited
+func ParseMaxNorm(s string, weightLayers int) ([]float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	splits := strings.Split(s, ",")
+	if len(splits) != 1 && len(splits) != weightLayers {
+		return nil, fmt.Errorf("expected 1 or %d max-norms, got %d", weightLayers, len(splits))
+	}
+	norms := make([]float64, len(splits))
+	for i, split := range splits {
+		norm, err := strconv.ParseFloat(strings.TrimSpace(split), 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing max-norm %d: %w", i+1, err)
+		}
+		if norm < 0 {
+			return nil, fmt.Errorf("max-norm %g cannot be negative", norm)
+		}
+		norms[i] = norm
+	}
+	return norms, nil
+}
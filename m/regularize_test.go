@@ -0,0 +1,91 @@
+package m
+
+import (
+	"gonum.org/v1/gonum/mat"
+	"math"
+	"testing"
+)
+
+func TestRegularize(t *testing.T) {
+	tests := []struct {
+		name     string
+		p        Parameter
+		values   []float64
+		expected []float64
+	}{
+		{"none", Parameter{}, []float64{2, -1, 0.05, 0}, []float64{2, -1, 0.05, 0}},
+		{"l2", Parameter{L2: 0.5}, []float64{2, -1, 0.05, 0}, []float64{1.9, -0.95, 0.0475, 0}},
+		// the L1 step is 0.1, which stops at zero rather than pushing small weights past it
+		{"l1", Parameter{L1: 1}, []float64{2, -1, 0.05, -0.1}, []float64{1.9, -0.9, 0, 0}},
+		{"l1 and l2", Parameter{L1: 1, L2: 0.5}, []float64{2, -1, 0.05, 0}, []float64{1.8, -0.85, 0, 0}},
+		// each row is a node's incoming weights, so only the first row is over the limit
+		{"max-norm", Parameter{MaxNorm: 1}, []float64{3, 4, 0.3, 0.4}, []float64{0.6, 0.8, 0.3, 0.4}},
+		{"max-norm after l2", Parameter{L2: 1, MaxNorm: 1}, []float64{3, 4, 0.3, 0.4}, []float64{0.6, 0.8, 0.27, 0.36}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.p
+			p.Value = mat.NewDense(2, 2, tt.values)
+			regularize(&p, 0.1)
+			if got := p.Value.RawMatrix().Data; !closeValues(got, tt.expected) {
+				t.Errorf("got %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPenalty(t *testing.T) {
+	weights := mat.NewDense(1, 2, []float64{2, -1})
+	net := Network{sequential: &Sequential{Layers: []Layer{
+		NewDense(weights, mat.NewDense(1, 1, []float64{5}), 0.1, 0.5, 0),
+		NewDense(mat.NewDense(1, 1, []float64{3}), nil, 0, 0, 1),
+	}}}
+	// biases and unpenalized weights add nothing: 0.1*(2+1) + 0.5*(4+1)/2
+	if penalty := net.penalty(); math.Abs(penalty-1.55) > 1e-9 {
+		t.Errorf("got %g, expected 1.55", penalty)
+	}
+}
+
+func TestMaxNormConfig(t *testing.T) {
+	tests := []struct {
+		maxNorm  []float64
+		expected []float64
+	}{
+		{nil, []float64{0, 0, 0}},
+		{[]float64{3}, []float64{3, 3, 3}},
+		{[]float64{1, 0, 2}, []float64{1, 0, 2}},
+	}
+	for _, tt := range tests {
+		c := Config{MaxNorm: tt.maxNorm}
+		for i, expected := range tt.expected {
+			if got := c.maxNorm(i); got != expected {
+				t.Errorf("%v: layer %d got %g, expected %g", tt.maxNorm, i, got, expected)
+			}
+		}
+	}
+}
+
+func TestParseMaxNorm(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected []float64
+		err      bool
+	}{
+		{"", nil, false},
+		{"3", []float64{3}, false},
+		{"1, 0,2.5", []float64{1, 0, 2.5}, false},
+		{"1,2", nil, true},
+		{"1,x,2", nil, true},
+		{"-1", nil, true},
+	}
+	for _, tt := range tests {
+		norms, err := ParseMaxNorm(tt.s, 3)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: got error %v, expected an error: %t", tt.s, err, tt.err)
+			continue
+		}
+		if !closeValues(norms, tt.expected) {
+			t.Errorf("%q: got %v, expected %v", tt.s, norms, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package m
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// runInfo describes a single training run recorded in the analysis log
+type runInfo struct {
+	name         string
+	endTime      string
+	targetLabels []string
+	activator    Activator
+	mode         string
+	settings     map[string]string
+	// tested is false when there was no test file, and score is the accuracy of a classifier or the R² of a
+	// regression or an autoencoder's reconstructions, so that higher is always better
+	tested bool
+	score  float64
+}
+
+var analysisHeaders = []string{
+	"Name", "Activator", "Inputs", "Hiddens", "Outputs", "Layers", "Epochs", "Target Labels", "LR", "End Time",
+	"SecondsToTrain", "Accuracy", "Mode", "Settings", "Metrics",
+}
+
+const csvRecords = 15
+
+// legacyCSVRecords is the number of columns before modes, settings and metrics were recorded
+const legacyCSVRecords = 12
+
+// upgradeAnalysisLog rewrites an analysis log from before modes were recorded with the current headers. Old runs
+// were all classifiers initialized by fan-in and trained with the mean squared error.
+func upgradeAnalysisLog() error {
+	file, err := os.Open(analysisFilepath)
+	if err != nil {
+		return err
+	}
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("reading analysis log: %w", err)
+	}
+	if len(records) == 0 || len(records[0]) != legacyCSVRecords {
+		return nil
+	}
+	records[0] = analysisHeaders
+	for i := 1; i < len(records); i++ {
+		if len(records[i]) == legacyCSVRecords {
+			records[i] = append(records[i], ModeClassification, formatPairs(map[string]string{
+				"init": InitFanIn,
+				"loss": LossMSE,
+			}), "")
+		}
+	}
+	file, err = os.Create(analysisFilepath)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(file)
+	err = w.WriteAll(records)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("writing analysis log: %w", err)
+	}
+	return file.Close()
+}
+
+// runsFor reads every run of the named dataset from the analysis log in the order they were recorded
+func runsFor(name string) ([]runInfo, error) {
+	file, err := os.Open(analysisFilepath)
+	if err != nil {
+		return nil, fmt.Errorf("opening analysis csv file: %w", err)
+	}
+	defer file.Close()
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1
+	var runs []runInfo
+	var columns int
+	i := 0
+	// Iterate through the records
+	for {
+		// Read each record from csv
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading record: %w", err)
+		}
+		if i == 0 {
+			columns = len(record)
+			if columns != csvRecords && columns != legacyCSVRecords {
+				return nil, fmt.Errorf("there are %d analysis csv headers, expected %d", len(record), csvRecords)
+			}
+		} else if len(record) != columns {
+			return nil, fmt.Errorf("there are %d analysis csv values in record %d, expected %d", len(record), i, columns)
+		}
+		i++
+		// record[0] is name
+		// record[1] is activator
+		// record[7] is the comma separated list of target labels
+		// record[9] is time ending (epoch time)
+		// record[11] is Accuracy
+		// record[12] is the mode, record[13] the settings and record[14] the metrics of the run
+		if i == 1 || record[0] != name {
+			continue
+		}
+		run := runInfo{
+			name:    name,
+			endTime: record[9],
+		}
+		var ok bool
+		run.activator, ok = ActivatorLookup[record[1]]
+		if !ok {
+			return nil, fmt.Errorf("invalid activator: %s", record[1])
+		}
+		run.targetLabels = strings.Split(record[7], ",")
+		for i := range run.targetLabels {
+			run.targetLabels[i] = strings.TrimSpace(run.targetLabels[i])
+		}
+		run.mode = ModeClassification
+		if columns == csvRecords {
+			run.mode = modeOrDefault(record[12])
+			run.settings = parsePairs(record[13])
+		}
+		if run.mode == ModeRegression || run.mode == ModeAutoencoder {
+			run.score, err = strconv.ParseFloat(parsePairs(record[14])["r2"], 64)
+		} else {
+			run.score, err = strconv.ParseFloat(record[11], 64)
+		}
+		run.tested = err == nil
+		runs = append(runs, run)
+	}
+
+	return runs, nil
+}
+
+// defaultModeRuns reads the runs of the named dataset in its default mode, which is the mode of its first recorded
+// run. Scores of different modes aren't comparable, an accuracy percent being far above any R², so runs are only
+// ranked against others of the same mode, and a run in another mode never changes which run a bare name loads. Runs
+// in other modes are loaded by tagging them.
+func defaultModeRuns(name string) ([]runInfo, error) {
+	runs, err := runsFor(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("no runs of %s in %s", name, analysisFilepath)
+	}
+	mode := runs[0].mode
+	var same []runInfo
+	for _, run := range runs {
+		if run.mode == mode {
+			same = append(same, run)
+		}
+	}
+	return same, nil
+}
+
+// bestRun takes a dataset name and returns the most accurate run in its default mode. An untested run is only chosen
+// if no run of the dataset in that mode has been tested.
+func bestRun(name string) (runInfo, error) {
+	runs, err := defaultModeRuns(name)
+	if err != nil {
+		return runInfo{}, err
+	}
+	best := runs[0]
+	for _, run := range runs[1:] {
+		if run.tested && (!best.tested || run.score > best.score) {
+			best = run
+		}
+	}
+
+	return best, nil
+}
+
+// runFor finds a specific run of the named dataset by its ending time
+func runFor(name, endTime string) (runInfo, error) {
+	runs, err := runsFor(name)
+	if err != nil {
+		return runInfo{}, err
+	}
+	for _, run := range runs {
+		if run.endTime == endTime {
+			return run, nil
+		}
+	}
+
+	return runInfo{}, fmt.Errorf("no run %s of %s in %s", endTime, name, analysisFilepath)
+}
+
+// splitRef splits a model reference of the form name or name@tag
+func splitRef(ref string) (name, tag string) {
+	splits := strings.SplitN(ref, "@", 2)
+	if len(splits) == 1 {
+		return splits[0], ""
+	}
+	return splits[0], splits[1]
+}
+
+// resolveRun finds the run a model reference points to. A bare dataset name refers to its most accurate run while
+// name@tag refers to the run the tag was last set to.
+func resolveRun(ref string) (runInfo, error) {
+	name, tag := splitRef(ref)
+	if tag == "" {
+		return bestRun(name)
+	}
+	endTime, err := taggedRun(name, tag)
+	if err != nil {
+		return runInfo{}, err
+	}
+	return runFor(name, endTime)
+}
+
+// resolveRuns finds the runs a model reference points to, which is a single run unless the reference is to an
+// ensemble of the form name:members[:combination]. The combination is empty for a single run.
+func resolveRuns(ref string) ([]runInfo, string, error) {
+	name, members, combination, ok := splitEnsembleRef(ref)
+	if !ok {
+		run, err := resolveRun(ref)
+		if err != nil {
+			return nil, "", err
+		}
+		return []runInfo{run}, "", nil
+	}
+	if err := CheckCombination(combination); err != nil {
+		return nil, "", err
+	}
+	runs, err := ensembleRuns(name, members)
+	if err != nil {
+		return nil, "", err
+	}
+	return runs, combination, nil
+}
+
+// ResolveRun returns the ending time identifying the run a model reference (name, name@tag or an ensemble such as
+// name:top5) points to. An ensemble is identified by the ending times of its members joined by +.
+func ResolveRun(ref string) (string, error) {
+	runs, _, err := resolveRuns(ref)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %w", ref, err)
+	}
+	return ensembleRun(runs), nil
+}
+
+// LoadModel loads the model a reference of the form name or name@tag points to, or the ensemble of the runs a
+// reference of the form name:members[:combination] points to
+func LoadModel(ref string) (*Model, error) {
+	runs, combination, err := resolveRuns(ref)
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s: %w", ref, err)
+	}
+	members := make([]*Model, len(runs))
+	for i, run := range runs {
+		members[i], err = load(run)
+		if err != nil {
+			return nil, fmt.Errorf("loading network: %w", err)
+		}
+	}
+	if combination == "" {
+		return members[0], nil
+	}
+	model, err := newEnsemble(runs, members, combination)
+	if err != nil {
+		return nil, fmt.Errorf("combining %s: %w", ref, err)
+	}
+
+	return model, nil
+}
+
+// BestNetworkFor loads the most accurate training run of the named dataset as a network that can only predict.
+//
+// Deprecated: Use BestModelFor, whose Model is safe for concurrent use.
+func BestNetworkFor(name string) (Network, error) {
+	model, err := BestModelFor(name)
+	if err != nil {
+		return Network{}, err
+	}
+	return Network{model: model}, nil
+}
+
+// BestModelFor loads the model from the most accurate training run of the named dataset
+func BestModelFor(name string) (*Model, error) {
+	run, err := bestRun(name)
+	if err != nil {
+		return nil, fmt.Errorf("getting best epoch for %s: %w", name, err)
+	}
+	model, err := load(run)
+	if err != nil {
+		return nil, fmt.Errorf("loading network: %w", err)
+	}
+
+	return model, nil
+}
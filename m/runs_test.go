@@ -0,0 +1,104 @@
+package m
+
+import (
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// withAnalysisLog points the analysis log at a temporary file holding runs of the form endTime/mode/accuracy/r2
+func withAnalysisLog(t *testing.T, runs ...string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "gophernet")
+	if err != nil {
+		t.Fatalf("creating directory: %s", err)
+	}
+	filename := filepath.Join(dir, "analysis.csv")
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatalf("creating analysis log: %s", err)
+	}
+	w := csv.NewWriter(file)
+	w.Write(analysisHeaders)
+	for _, run := range runs {
+		splits := strings.Split(run, "/")
+		w.Write([]string{"digits", "sigmoid", "64", "30", "10", "3", "6", "0, 1", "0.0500", splits[0], "1",
+			splits[2], splits[1], "", "r2=" + splits[3]})
+	}
+	w.Flush()
+	if err := file.Close(); err != nil {
+		t.Fatalf("writing analysis log: %s", err)
+	}
+	previous := analysisFilepath
+	analysisFilepath = filename
+	t.Cleanup(func() {
+		analysisFilepath = previous
+		os.RemoveAll(dir)
+	})
+}
+
+func TestBestRun(t *testing.T) {
+	tests := []struct {
+		name string
+		runs []string
+		best string
+	}{
+		{
+			name: "most accurate",
+			runs: []string{"1/classification/90/", "2/classification/95/", "3/classification/92/"},
+			best: "2",
+		},
+		{
+			name: "untested only without tested runs",
+			runs: []string{"1/classification/?/", "2/classification/80/", "3/classification/?/"},
+			best: "2",
+		},
+		{
+			name: "default mode autoencoder",
+			runs: []string{"1/autoencoder/?/0.6", "2/autoencoder/?/0.8", "3/autoencoder/?/0.7"},
+			best: "2",
+		},
+		{
+			name: "later runs in another mode",
+			runs: []string{"1/classification/60/", "2/autoencoder/?/0.9", "3/classification/70/", "4/regression/?/0.99"},
+			best: "3",
+		},
+		{
+			name: "first run in another mode",
+			runs: []string{"1/autoencoder/?/0.2", "2/classification/95/", "3/classification/90/"},
+			best: "1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withAnalysisLog(t, tt.runs...)
+			run, err := bestRun("digits")
+			if err != nil {
+				t.Fatalf("finding best run: %s", err)
+			}
+			if run.endTime != tt.best {
+				t.Errorf("got run %s, expected %s", run.endTime, tt.best)
+			}
+		})
+	}
+}
+
+func TestTopRuns(t *testing.T) {
+	withAnalysisLog(t, "1/autoencoder/?/0.5", "2/classification/90/", "3/autoencoder/?/0.9", "4/autoencoder/?/",
+		"5/autoencoder/?/0.7", "6/classification/95/")
+	runs, err := topRuns("digits", 2)
+	if err != nil {
+		t.Fatalf("finding top runs: %s", err)
+	}
+	var endTimes []string
+	for _, run := range runs {
+		endTimes = append(endTimes, run.endTime)
+	}
+	if expected := []string{"3", "5"}; !reflect.DeepEqual(endTimes, expected) {
+		t.Errorf("got runs %v, expected %v", endTimes, expected)
+	}
+}
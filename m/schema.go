@@ -0,0 +1,414 @@
+package m
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Column types and categorical encodings understood by a Schema
+const (
+	ColumnNumeric     = "numeric"
+	ColumnCategorical = "categorical"
+	ColumnIgnore      = "ignore"
+
+	EncodingOrdinal = "ordinal"
+	EncodingOneHot  = "onehot"
+)
+
+// Schema describes the columns of a raw dataset and how each is encoded into the normalized space separated format
+// read by GetLines. The target column is always written last.
+type Schema struct {
+	// Delimiter separates the values of a row. It defaults to a comma, and "whitespace" splits on any run of spaces
+	// or tabs.
+	Delimiter string `json:"delimiter,omitempty"`
+	// Header skips a row of column names after any skipped lines
+	Header bool `json:"header,omitempty"`
+	// Skip is a number of lines to skip at the start of the file
+	Skip int `json:"skip,omitempty"`
+	// Missing is the value used for unknown values. Rows missing a target are unlabeled and skipped.
+	Missing string   `json:"missing,omitempty"`
+	Columns []Column `json:"columns"`
+	Target  string   `json:"target"`
+}
+
+// Column describes one raw column
+type Column struct {
+	Name string `json:"name"`
+	// Type is numeric, categorical or ignore
+	Type string `json:"type"`
+	// Min and Max are the range numeric values are scaled from into 0..1. Either is detected from the data if left out.
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+	// Encoding is ordinal (the default), which scales the category's position into 0..1, or onehot, which uses a
+	// value per category. Targets are always one-hot encoded.
+	Encoding string `json:"encoding,omitempty"`
+	// Categories lists the values of a categorical column in order. They are detected in order of appearance if
+	// left out.
+	Categories []string `json:"categories,omitempty"`
+	// Repeat expands this column into that many columns suffixed with their index, such as the 64 pixels of a digit
+	Repeat int `json:"repeat,omitempty"`
+}
+
+// ReadSchema reads a JSON schema file
+func ReadSchema(filename string) (Schema, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return Schema{}, fmt.Errorf("opening schema: %w", err)
+	}
+	defer file.Close()
+	var s Schema
+	err = json.NewDecoder(file).Decode(&s)
+	if err != nil {
+		return Schema{}, fmt.Errorf("decoding schema: %w", err)
+	}
+	return s.expand()
+}
+
+// expand repeats columns and checks that every column and the target are valid
+func (s Schema) expand() (Schema, error) {
+	var columns []Column
+	for _, c := range s.Columns {
+		if c.Repeat <= 1 {
+			c.Repeat = 0
+			columns = append(columns, c)
+			continue
+		}
+		for i := 0; i < c.Repeat; i++ {
+			repeated := c
+			repeated.Name = fmt.Sprintf("%s%d", c.Name, i)
+			repeated.Repeat = 0
+			columns = append(columns, repeated)
+		}
+	}
+	s.Columns = columns
+	var hasTarget bool
+	for i, c := range s.Columns {
+		switch c.Type {
+		case ColumnNumeric, ColumnIgnore:
+		case ColumnCategorical:
+			if c.Encoding == "" {
+				s.Columns[i].Encoding = EncodingOrdinal
+			} else if c.Encoding != EncodingOrdinal && c.Encoding != EncodingOneHot {
+				return Schema{}, fmt.Errorf("column %s has invalid encoding %s", c.Name, c.Encoding)
+			}
+		default:
+			return Schema{}, fmt.Errorf("column %s has invalid type %s", c.Name, c.Type)
+		}
+		if c.Name == s.Target {
+			if c.Type == ColumnIgnore {
+				return Schema{}, fmt.Errorf("target column %s cannot be ignored", c.Name)
+			}
+			hasTarget = true
+		}
+	}
+	if !hasTarget {
+		return Schema{}, fmt.Errorf("target column %s is not in the schema", s.Target)
+	}
+	return s, nil
+}
+
+// ReadRows splits every row of a raw dataset into values after skipping lines, the header and blank lines
+func (s Schema) ReadRows(r io.Reader) ([][]string, []int, error) {
+	scanner := bufio.NewScanner(r)
+	var rows [][]string
+	var lineNums []int
+	var lineNum int
+	skip := s.Skip
+	if s.Header {
+		skip++
+	}
+	for scanner.Scan() {
+		lineNum++
+		if lineNum <= skip || strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		values := s.split(scanner.Text())
+		if len(values) != len(s.Columns) {
+			return nil, nil, InvalidLineError{
+				Line:     lineNum,
+				Values:   len(values),
+				Expected: len(s.Columns),
+			}
+		}
+		rows = append(rows, values)
+		lineNums = append(lineNums, lineNum)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("reading rows: %w", err)
+	}
+	return rows, lineNums, nil
+}
+
+func (s Schema) split(row string) []string {
+	var values []string
+	switch s.Delimiter {
+	case "whitespace":
+		values = strings.Fields(row)
+	case "":
+		values = strings.Split(row, ",")
+	default:
+		values = strings.Split(row, s.Delimiter)
+	}
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	return values
+}
+
+// Fit returns a copy of the schema with every range and category list that was left out detected from the rows
+func (s Schema) Fit(rows [][]string) (Schema, error) {
+	fitted := s
+	fitted.Columns = make([]Column, len(s.Columns))
+	for i, c := range s.Columns {
+		switch c.Type {
+		case ColumnNumeric:
+			if c.Min != nil && c.Max != nil {
+				break
+			}
+			low, high := math.Inf(1), math.Inf(-1)
+			for j, row := range rows {
+				if row[i] == s.Missing && s.Missing != "" {
+					continue
+				}
+				num, err := strconv.ParseFloat(row[i], 64)
+				if err != nil {
+					return Schema{}, fmt.Errorf("row %d, column %s: %w", j+1, c.Name, err)
+				}
+				low = math.Min(low, num)
+				high = math.Max(high, num)
+			}
+			if c.Min == nil {
+				c.Min = &low
+			}
+			if c.Max == nil {
+				c.Max = &high
+			}
+		case ColumnCategorical:
+			if len(c.Categories) > 0 {
+				break
+			}
+			seen := make(map[string]bool)
+			for _, row := range rows {
+				if seen[row[i]] || (row[i] == s.Missing && s.Missing != "") {
+					continue
+				}
+				seen[row[i]] = true
+				c.Categories = append(c.Categories, row[i])
+			}
+		}
+		fitted.Columns[i] = c
+	}
+	return fitted, nil
+}
+
+func (s Schema) targetColumn() Column {
+	for _, c := range s.Columns {
+		if c.Name == s.Target {
+			return c
+		}
+	}
+	return Column{}
+}
+
+// InputNum is the number of input values a row is encoded into
+func (s Schema) InputNum() int {
+	var n int
+	for _, c := range s.Columns {
+		if c.Name != s.Target {
+			n += c.width()
+		}
+	}
+	return n
+}
+
+// OutputNum is the number of target values a row is encoded into
+func (s Schema) OutputNum() int {
+	target := s.targetColumn()
+	if target.Type == ColumnCategorical {
+		return len(target.Categories)
+	}
+	return 1
+}
+
+// Labels are the target categories, which name each output. A numeric target has a single output named after it.
+func (s Schema) Labels() []string {
+	target := s.targetColumn()
+	if target.Type == ColumnNumeric {
+		return []string{target.Name}
+	}
+	return target.Categories
+}
+
+// IsRegression reports whether the target is numeric, so a network trained on it predicts values
+func (s Schema) IsRegression() bool {
+	return s.targetColumn().Type == ColumnNumeric
+}
+
+func (c Column) width() int {
+	switch {
+	case c.Type == ColumnIgnore:
+		return 0
+	case c.Type == ColumnCategorical && c.Encoding == EncodingOneHot:
+		return len(c.Categories)
+	}
+	return 1
+}
+
+// errMissingTarget is returned when encoding an unlabeled row
+var errMissingTarget = errors.New("missing target")
+
+// IsMissingTarget reports whether encoding failed only because the row is unlabeled
+func IsMissingTarget(err error) bool {
+	return errors.Is(err, errMissingTarget)
+}
+
+// Encode converts a raw row into normalized inputs and one-hot targets using a fitted schema
+func (s Schema) Encode(row []string) (inputs, targets []float64, err error) {
+	if len(row) != len(s.Columns) {
+		return nil, nil, fmt.Errorf("expected %d values, got %d", len(s.Columns), len(row))
+	}
+	inputs = make([]float64, 0, s.InputNum())
+	for i, c := range s.Columns {
+		if c.Type == ColumnIgnore {
+			continue
+		}
+		if c.Name == s.Target {
+			if row[i] == s.Missing && s.Missing != "" {
+				return nil, nil, errMissingTarget
+			}
+			targets, err = c.encodeTarget(row[i])
+		} else {
+			inputs, err = c.encode(inputs, row[i])
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("column %s: %w", c.Name, err)
+		}
+	}
+	return inputs, targets, nil
+}
+
+func (c Column) index(value string) (int, error) {
+	for i, category := range c.Categories {
+		if category == value {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid value %s", value)
+}
+
+// encode appends the normalized values for a raw input value
+func (c Column) encode(inputs []float64, value string) ([]float64, error) {
+	if c.Type == ColumnNumeric {
+		num, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, err
+		}
+		return append(inputs, normalize(*c.Min, *c.Max, num)), nil
+	}
+	index, err := c.index(value)
+	if err != nil {
+		return nil, err
+	}
+	if c.Encoding == EncodingOneHot {
+		for i := range c.Categories {
+			if i == index {
+				inputs = append(inputs, 1)
+			} else {
+				inputs = append(inputs, 0)
+			}
+		}
+		return inputs, nil
+	}
+	return append(inputs, normalize(0, float64(len(c.Categories)-1), float64(index))), nil
+}
+
+func (c Column) encodeTarget(value string) ([]float64, error) {
+	if c.Type == ColumnNumeric {
+		return c.encode(nil, value)
+	}
+	index, err := c.index(value)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]float64, len(c.Categories))
+	targets[index] = 1
+	return targets, nil
+}
+
+// normalize scales input from min..max into 0..1. A column with a single value is scaled to 0.
+func normalize(min, max, input float64) float64 {
+	if max == min {
+		return 0
+	}
+	return (input - min) / (max - min)
+}
+
+// EncodeInputs converts raw input values keyed by column name into normalized inputs using a fitted schema. Every
+// input column must have a value.
+func (s Schema) EncodeInputs(values map[string]string) ([]float64, error) {
+	known := make(map[string]bool, len(s.Columns))
+	inputs := make([]float64, 0, s.InputNum())
+	var err error
+	for _, c := range s.Columns {
+		known[c.Name] = true
+		if c.Type == ColumnIgnore || c.Name == s.Target {
+			continue
+		}
+		value, ok := values[c.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing value for column %s", c.Name)
+		}
+		inputs, err = c.encode(inputs, value)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", c.Name, err)
+		}
+	}
+	for name := range values {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown column %s", name)
+		}
+	}
+	return inputs, nil
+}
+
+// ParseRawQuery splits a query of the form Wind=Strong,Water=Cold into raw values keyed by column name
+func ParseRawQuery(query string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, pair := range strings.Split(query, ",") {
+		splits := strings.SplitN(pair, "=", 2)
+		if len(splits) != 2 {
+			return nil, fmt.Errorf("expected column=value, got %s", pair)
+		}
+		values[strings.TrimSpace(splits[0])] = strings.TrimSpace(splits[1])
+	}
+	return values, nil
+}
+
+// TransformPath is where the fitted schema for a prepared data file is kept, such as fishing.transform.json for
+// fishing.data
+func TransformPath(dataFilename string) string {
+	return strings.TrimSuffix(dataFilename, ".data") + ".transform.json"
+}
+
+// WriteSchema writes a schema as indented JSON
+func WriteSchema(filename string, s Schema) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("creating schema file: %w", err)
+	}
+	enc := json.NewEncoder(file)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(s)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("encoding schema: %w", err)
+	}
+	return file.Close()
+}
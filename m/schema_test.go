@@ -0,0 +1,131 @@
+package m
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestSchemaEncode encodes the raw datasets with their committed transforms, which must give the committed prepared
+// data files line for line, both when encoding whole rows and when encoding raw inputs keyed by column name
+func TestSchemaEncode(t *testing.T) {
+	tests := []struct {
+		transform string
+		raw       string
+		data      string
+	}{
+		{"../fishing.transform.json", "../data/raw/fishing.data", "../fishing.data"},
+		{"../digits.transform.json", "../data/raw/digits-training.data", "../digits.data"},
+	}
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.data), func(t *testing.T) {
+			transform, err := ReadSchema(tt.transform)
+			if err != nil {
+				t.Fatalf("reading transform: %s", err)
+			}
+			// the transform is written and read back unchanged
+			dir, err := ioutil.TempDir("", "gophernet")
+			if err != nil {
+				t.Fatalf("creating directory: %s", err)
+			}
+			defer os.RemoveAll(dir)
+			filename := filepath.Join(dir, "transform.json")
+			if err := WriteSchema(filename, transform); err != nil {
+				t.Fatalf("writing transform: %s", err)
+			}
+			transform, err = ReadSchema(filename)
+			if err != nil {
+				t.Fatalf("reading written transform: %s", err)
+			}
+
+			file, err := os.Open(tt.raw)
+			if err != nil {
+				t.Fatalf("opening raw data: %s", err)
+			}
+			defer file.Close()
+			rows, lineNums, err := transform.ReadRows(file)
+			if err != nil {
+				t.Fatalf("reading raw rows: %s", err)
+			}
+			lines, err := ReadAll(FileDataset{
+				Path:   tt.data,
+				Format: ReadOptions{InputNum: transform.InputNum(), OutputNum: transform.OutputNum()},
+			})
+			if err != nil {
+				t.Fatalf("reading prepared data: %s", err)
+			}
+
+			var encoded int
+			for i, row := range rows {
+				inputs, targets, err := transform.Encode(row)
+				if IsMissingTarget(err) {
+					continue
+				}
+				if err != nil {
+					t.Fatalf("line %d: encoding: %s", lineNums[i], err)
+				}
+				if encoded >= len(lines) {
+					t.Fatalf("line %d: encoded more rows than the %d prepared lines", lineNums[i], len(lines))
+				}
+				line := lines[encoded]
+				encoded++
+				if !closeValues(inputs, line.Inputs) || !closeValues(targets, line.Targets) {
+					t.Fatalf("line %d: encoded %v %v, prepared %v %v", lineNums[i], inputs, targets, line.Inputs,
+						line.Targets)
+				}
+
+				values := make(map[string]string, len(row))
+				for j, c := range transform.Columns {
+					if c.Name != transform.Target {
+						values[c.Name] = row[j]
+					}
+				}
+				raw, err := transform.EncodeInputs(values)
+				if err != nil {
+					t.Fatalf("line %d: encoding raw inputs: %s", lineNums[i], err)
+				}
+				if !closeValues(raw, inputs) {
+					t.Fatalf("line %d: raw inputs encoded as %v, the row as %v", lineNums[i], raw, inputs)
+				}
+			}
+			if encoded != len(lines) {
+				t.Errorf("encoded %d rows, expected the %d prepared lines", encoded, len(lines))
+			}
+		})
+	}
+}
+
+func TestSchemaEncodeInputsErrors(t *testing.T) {
+	transform, err := ReadSchema("../fishing.transform.json")
+	if err != nil {
+		t.Fatalf("reading transform: %s", err)
+	}
+	tests := []struct {
+		name   string
+		values map[string]string
+	}{
+		{"missing column", map[string]string{"Wind": "Strong", "Water": "Cold", "Air": "Warm"}},
+		{"unknown column", map[string]string{"Wind": "Strong", "Water": "Cold", "Air": "Warm", "Forecast": "Sunny", "Tide": "High"}},
+		{"unknown category", map[string]string{"Wind": "Gale", "Water": "Cold", "Air": "Warm", "Forecast": "Sunny"}},
+	}
+	for _, tt := range tests {
+		if _, err := transform.EncodeInputs(tt.values); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+// closeValues reports whether values match to the precision of the prepared files
+func closeValues(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
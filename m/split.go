@@ -0,0 +1,173 @@
+package m
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// Ways of balancing the classes of a training set
+const (
+	// BalanceOversample repeats random lines of each class until it's as large as the largest class
+	BalanceOversample = "oversample"
+	// BalanceUndersample drops random lines of each class until it's as small as the smallest class
+	BalanceUndersample = "undersample"
+)
+
+// SplitOptions describes how lines are divided into train and test sets
+type SplitOptions struct {
+	// Test is the fraction of lines put in the test set
+	Test float64
+	// Stratify splits each class separately so that both sets keep the class ratios of the whole
+	Stratify bool
+	// Balance is empty, oversample or undersample, and is only applied to the training set
+	Balance string
+	Rng     *rand.Rand
+}
+
+// hotIndex is the index of the first target that rounds to 1, or -1 when there is none
+func hotIndex(targets []float64) int {
+	for i, t := range targets {
+		if int(t+math.Copysign(0.5, t)) == 1 {
+			return i
+		}
+	}
+	return -1
+}
+
+// Split shuffles lines and divides them into train and test sets
+func Split(lines Lines, opts SplitOptions) (train, test Lines, err error) {
+	if opts.Test < 0 || opts.Test >= 1 {
+		return nil, nil, fmt.Errorf("test fraction must be at least 0 and less than 1, got %g", opts.Test)
+	}
+	if opts.Balance != "" && opts.Balance != BalanceOversample && opts.Balance != BalanceUndersample {
+		return nil, nil, fmt.Errorf("invalid balance %s", opts.Balance)
+	}
+	groups := []Lines{lines}
+	if opts.Stratify {
+		groups = byClass(lines)
+	}
+	for _, group := range groups {
+		group = append(Lines(nil), group...)
+		opts.Rng.Shuffle(len(group), func(i, j int) {
+			group[i], group[j] = group[j], group[i]
+		})
+		testNum := int(math.Round(opts.Test * float64(len(group))))
+		test = append(test, group[:testNum]...)
+		train = append(train, group[testNum:]...)
+	}
+
+	switch opts.Balance {
+	case BalanceOversample:
+		train = oversample(byClass(train), opts.Rng)
+	case BalanceUndersample:
+		train = undersample(byClass(train), opts.Rng)
+	}
+	for _, set := range []Lines{train, test} {
+		opts.Rng.Shuffle(len(set), func(i, j int) {
+			set[i], set[j] = set[j], set[i]
+		})
+	}
+	return train, test, nil
+}
+
+// byClass groups lines by their hot target in order of the target index. Lines without a hot target are grouped
+// together last.
+func byClass(lines Lines) []Lines {
+	classes := make(map[int]Lines)
+	var order []int
+	for _, line := range lines {
+		class := hotIndex(line.Targets)
+		if _, ok := classes[class]; !ok {
+			order = append(order, class)
+		}
+		classes[class] = append(classes[class], line)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		if order[i] < 0 || order[j] < 0 {
+			return order[j] < 0 && order[i] >= 0
+		}
+		return order[i] < order[j]
+	})
+	groups := make([]Lines, len(order))
+	for i, class := range order {
+		groups[i] = classes[class]
+	}
+	return groups
+}
+
+func oversample(groups []Lines, rng *rand.Rand) Lines {
+	var largest int
+	for _, group := range groups {
+		if len(group) > largest {
+			largest = len(group)
+		}
+	}
+	var lines Lines
+	for _, group := range groups {
+		lines = append(lines, group...)
+		for i := len(group); i < largest; i++ {
+			lines = append(lines, group[rng.Intn(len(group))])
+		}
+	}
+	return lines
+}
+
+func undersample(groups []Lines, rng *rand.Rand) Lines {
+	smallest := -1
+	for _, group := range groups {
+		if smallest < 0 || len(group) < smallest {
+			smallest = len(group)
+		}
+	}
+	var lines Lines
+	for _, group := range groups {
+		for _, i := range rng.Perm(len(group))[:smallest] {
+			lines = append(lines, group[i])
+		}
+	}
+	return lines
+}
+
+// WriteLines writes lines as space separated inputs followed by targets, the format read by GetLines
+func WriteLines(w io.Writer, lines Lines) error {
+	buf := bufio.NewWriter(w)
+	for _, line := range lines {
+		values := append(append([]float64(nil), line.Inputs...), line.Targets...)
+		for i, v := range values {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			buf.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Flush()
+}
+
+// WriteSequences writes lines as sequences of steps of inputNum space separated values followed by their targets,
+// separated by semicolons, the sequence format
+func WriteSequences(w io.Writer, lines Lines, inputNum int) error {
+	buf := bufio.NewWriter(w)
+	for _, line := range lines {
+		for i, v := range line.Inputs {
+			if i > 0 && i%inputNum == 0 {
+				buf.WriteString("; ")
+			} else if i > 0 {
+				buf.WriteByte(' ')
+			}
+			buf.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		buf.WriteString(";")
+		for _, v := range line.Targets {
+			buf.WriteByte(' ')
+			buf.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Flush()
+}
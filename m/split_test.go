@@ -0,0 +1,144 @@
+package m
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// classLines returns counts[i] lines of class i, each with a distinct input
+func classLines(counts ...int) Lines {
+	var lines Lines
+	for class, count := range counts {
+		for i := 0; i < count; i++ {
+			targets := make([]float64, len(counts))
+			targets[class] = 1
+			lines = append(lines, Line{Inputs: []float64{float64(len(lines))}, Targets: targets})
+		}
+	}
+	return lines
+}
+
+func classCounts(lines Lines, classes int) []int {
+	counts := make([]int, classes)
+	for _, line := range lines {
+		counts[hotIndex(line.Targets)]++
+	}
+	return counts
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts []int
+		opts   SplitOptions
+		train  []int
+		test   []int
+	}{
+		{
+			name:   "stratified",
+			counts: []int{80, 20},
+			opts:   SplitOptions{Test: 0.25, Stratify: true},
+			train:  []int{60, 15},
+			test:   []int{20, 5},
+		},
+		{
+			name:   "stratified keeps small classes",
+			counts: []int{90, 10, 5},
+			opts:   SplitOptions{Test: 0.2, Stratify: true},
+			train:  []int{72, 8, 4},
+			test:   []int{18, 2, 1},
+		},
+		{
+			name:   "no test",
+			counts: []int{3, 2},
+			opts:   SplitOptions{Test: 0, Stratify: true},
+			train:  []int{3, 2},
+			test:   []int{0, 0},
+		},
+		{
+			name:   "oversample",
+			counts: []int{40, 10},
+			opts:   SplitOptions{Test: 0.2, Stratify: true, Balance: BalanceOversample},
+			train:  []int{32, 32},
+			test:   []int{8, 2},
+		},
+		{
+			name:   "undersample",
+			counts: []int{40, 10},
+			opts:   SplitOptions{Test: 0.2, Stratify: true, Balance: BalanceUndersample},
+			train:  []int{8, 8},
+			test:   []int{8, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Rng = rand.New(rand.NewSource(1))
+			train, test, err := Split(classLines(tt.counts...), tt.opts)
+			if err != nil {
+				t.Fatalf("splitting: %s", err)
+			}
+			if got := classCounts(train, len(tt.counts)); !reflect.DeepEqual(got, tt.train) {
+				t.Errorf("train has classes %v, expected %v", got, tt.train)
+			}
+			if got := classCounts(test, len(tt.counts)); !reflect.DeepEqual(got, tt.test) {
+				t.Errorf("test has classes %v, expected %v", got, tt.test)
+			}
+		})
+	}
+}
+
+func TestSplitKeepsEveryLineOnce(t *testing.T) {
+	lines := classLines(30, 17)
+	train, test, err := Split(lines, SplitOptions{Test: 0.3, Rng: rand.New(rand.NewSource(4))})
+	if err != nil {
+		t.Fatalf("splitting: %s", err)
+	}
+	seen := make(map[float64]bool)
+	for _, line := range append(train, test...) {
+		if seen[line.Inputs[0]] {
+			t.Fatalf("line %g is in the split more than once", line.Inputs[0])
+		}
+		seen[line.Inputs[0]] = true
+	}
+	if len(seen) != len(lines) {
+		t.Errorf("split has %d of the %d lines", len(seen), len(lines))
+	}
+	if len(test) != 14 {
+		t.Errorf("test has %d lines, expected 14", len(test))
+	}
+}
+
+func TestSplitSeed(t *testing.T) {
+	lines := classLines(50, 30, 20)
+	split := func(seed int64) (Lines, Lines) {
+		train, test, err := Split(lines, SplitOptions{Test: 0.2, Stratify: true, Rng: rand.New(rand.NewSource(seed))})
+		if err != nil {
+			t.Fatalf("splitting: %s", err)
+		}
+		return train, test
+	}
+	train1, test1 := split(7)
+	train2, test2 := split(7)
+	if !reflect.DeepEqual(train1, train2) || !reflect.DeepEqual(test1, test2) {
+		t.Error("the same seed gave different splits")
+	}
+	train3, _ := split(8)
+	if reflect.DeepEqual(train1, train3) {
+		t.Error("different seeds gave the same split")
+	}
+}
+
+func TestSplitInvalid(t *testing.T) {
+	tests := []SplitOptions{
+		{Test: -0.1},
+		{Test: 1},
+		{Test: 0.2, Balance: "even"},
+	}
+	for _, opts := range tests {
+		opts.Rng = rand.New(rand.NewSource(1))
+		if _, _, err := Split(classLines(2, 2), opts); err == nil {
+			t.Errorf("expected an error splitting with %+v", opts)
+		}
+	}
+}
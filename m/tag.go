@@ -0,0 +1,84 @@
+package m
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path"
+)
+
+var tagsFilepath = path.Join(outPath, "tags.csv")
+
+// readTags reads every name, tag and run record. A missing tags file has no records.
+func readTags() ([][]string, error) {
+	file, err := os.Open(tagsFilepath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening tags file: %w", err)
+	}
+	defer file.Close()
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = 3
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("reading tags: %w", err)
+	}
+	return records, nil
+}
+
+func taggedRun(name, tag string) (string, error) {
+	records, err := readTags()
+	if err != nil {
+		return "", err
+	}
+	for _, record := range records {
+		if record[0] == name && record[1] == tag {
+			return record[2], nil
+		}
+	}
+	return "", fmt.Errorf("no run of %s is tagged %s", name, tag)
+}
+
+// SetTag points a tag of the named dataset at a run so that it can be loaded as name@tag. An empty run tags the
+// most accurate run. The tagged run is returned.
+func SetTag(name, tag, endTime string) (string, error) {
+	var run runInfo
+	var err error
+	if endTime == "" {
+		run, err = bestRun(name)
+	} else {
+		run, err = runFor(name, endTime)
+	}
+	if err != nil {
+		return "", err
+	}
+	records, err := readTags()
+	if err != nil {
+		return "", err
+	}
+	var replaced bool
+	for _, record := range records {
+		if record[0] == name && record[1] == tag {
+			record[2] = run.endTime
+			replaced = true
+		}
+	}
+	if !replaced {
+		records = append(records, []string{name, tag, run.endTime})
+	}
+
+	file, err := os.Create(tagsFilepath)
+	if err != nil {
+		return "", fmt.Errorf("creating tags file: %w", err)
+	}
+	defer file.Close()
+	w := csv.NewWriter(file)
+	err = w.WriteAll(records)
+	if err != nil {
+		return "", fmt.Errorf("writing tags: %w", err)
+	}
+
+	return run.endTime, nil
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"github.com/PaluMacil/gophernet/m"
+	"time"
+)
+
+// PredictionMetrics counts predictions per model and most likely label and records their latency per model
+type PredictionMetrics struct {
+	predictions *Counter
+	latency     *Histogram
+}
+
+func NewPredictionMetrics(r *Registry) *PredictionMetrics {
+	return &PredictionMetrics{
+		predictions: r.NewCounter("gophernet_predictions_total",
+			"Number of predictions made per model and most likely label, which is empty for models without labels.",
+			"model", "label"),
+		latency: r.NewHistogram("gophernet_prediction_duration_seconds",
+			"Time taken to make a single prediction.", DefaultLatencyBuckets, "model"),
+	}
+}
+
+// ObservePrediction records a prediction of label made by model in duration
+func (pm *PredictionMetrics) ObservePrediction(model, label string, duration time.Duration) {
+	pm.predictions.Inc(model, label)
+	pm.latency.Observe(duration.Seconds(), model)
+}
+
+// TrainingMetrics exposes the progress of training runs. It implements m.TrainingObserver.
+type TrainingMetrics struct {
+	epoch              *Gauge
+	epochs             *Gauge
+	loss               *Gauge
+	learningRate       *Gauge
+	validationAccuracy *Gauge
+}
+
+func NewTrainingMetrics(r *Registry) *TrainingMetrics {
+	return &TrainingMetrics{
+		epoch: r.NewGauge("gophernet_training_epoch",
+			"Last completed training epoch.", "dataset"),
+		epochs: r.NewGauge("gophernet_training_epochs",
+			"Total number of epochs the training run will complete.", "dataset"),
+		loss: r.NewGauge("gophernet_training_loss",
+			"Mean loss over the training set during the last completed epoch.", "dataset"),
+		learningRate: r.NewGauge("gophernet_training_learning_rate",
+			"Learning rate used during the last completed epoch.", "dataset"),
+		validationAccuracy: r.NewGauge("gophernet_training_validation_accuracy",
+			"Percent of the test set predicted correctly after the last completed epoch.", "dataset"),
+	}
+}
+
+func (tm *TrainingMetrics) ObserveEpoch(stats m.EpochStats) {
+	tm.epoch.Set(float64(stats.Epoch), stats.Name)
+	tm.epochs.Set(float64(stats.Epochs), stats.Name)
+	tm.loss.Set(stats.Loss, stats.Name)
+	tm.learningRate.Set(stats.LearningRate, stats.Name)
+	if stats.Validated {
+		tm.validationAccuracy.Set(stats.ValidationAccuracy, stats.Name)
+	}
+}
+
+var _ m.TrainingObserver = (*TrainingMetrics)(nil)
@@ -0,0 +1,254 @@
+// Package metrics keeps counters, gauges and histograms and exposes them in the Prometheus text exposition format
+// without depending on a client library.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds every metric exposed together on one endpoint
+type Registry struct {
+	mu      sync.Mutex
+	metrics []*vec
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// DefaultLatencyBuckets are upper bounds in seconds suited to predictions taking microseconds to a second
+var DefaultLatencyBuckets = []float64{.00001, .000025, .00005, .0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+// vec is a metric family: one series per combination of label values
+type vec struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	// counts holds the non-cumulative count of each bucket for histograms, with +Inf last
+	counts []uint64
+	count  uint64
+}
+
+func (r *Registry) register(name, help, kind string, buckets []float64, labels []string) *vec {
+	v := &vec{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	r.mu.Lock()
+	r.metrics = append(r.metrics, v)
+	r.mu.Unlock()
+	return v
+}
+
+func (v *vec) with(labelValues []string) *series {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if v.kind == kindHistogram {
+			s.counts = make([]uint64, len(v.buckets)+1)
+		}
+		v.series[key] = s
+	}
+	return s
+}
+
+// Counter is a value that only goes up, such as the number of predictions made
+type Counter struct {
+	v *vec
+}
+
+// NewCounter registers a counter partitioned by the given label names
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{r.register(name, help, kindCounter, nil, labels)}
+}
+
+// Add increases the series for the label values by delta, which must not be negative
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic(fmt.Sprintf("counter %s cannot decrease", c.v.name))
+	}
+	c.v.mu.Lock()
+	c.v.with(labelValues).value += delta
+	c.v.mu.Unlock()
+}
+
+// Inc increases the series for the label values by one
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Gauge is a value that can go up and down, such as the current epoch
+type Gauge struct {
+	v *vec
+}
+
+// NewGauge registers a gauge partitioned by the given label names
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r.register(name, help, kindGauge, nil, labels)}
+}
+
+// Set replaces the value of the series for the label values
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.v.mu.Lock()
+	g.v.with(labelValues).value = value
+	g.v.mu.Unlock()
+}
+
+// Histogram counts observations in buckets, such as prediction latencies
+type Histogram struct {
+	v *vec
+}
+
+// NewHistogram registers a histogram with the given ascending bucket upper bounds partitioned by the given label
+// names. A +Inf bucket is always added.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return &Histogram{r.register(name, help, kindHistogram, sorted, labels)}
+}
+
+// Observe adds a value to the series for the label values
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.v.mu.Lock()
+	s := h.v.with(labelValues)
+	i := sort.SearchFloat64s(h.v.buckets, value)
+	s.counts[i]++
+	s.count++
+	s.value += value
+	h.v.mu.Unlock()
+}
+
+// WriteTo writes every metric in the text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	r.mu.Lock()
+	metrics := append([]*vec(nil), r.metrics...)
+	r.mu.Unlock()
+	for _, v := range metrics {
+		v.writeTo(cw)
+	}
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
+
+// ServeHTTP exposes the metrics for scraping
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = r.WriteTo(w)
+}
+
+func (v *vec) writeTo(w *countingWriter) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	w.printf("# HELP %s %s\n", v.name, escapeHelp(v.help))
+	w.printf("# TYPE %s %s\n", v.name, v.kind)
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := v.series[key]
+		if v.kind != kindHistogram {
+			w.printf("%s%s %s\n", v.name, formatLabels(v.labels, s.labelValues, "", ""), formatValue(s.value))
+			continue
+		}
+		var cumulative uint64
+		for i, count := range s.counts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(v.buckets) {
+				le = formatValue(v.buckets[i])
+			}
+			w.printf("%s_bucket%s %d\n", v.name, formatLabels(v.labels, s.labelValues, "le", le), cumulative)
+		}
+		w.printf("%s_sum%s %s\n", v.name, formatLabels(v.labels, s.labelValues, "", ""), formatValue(s.value))
+		w.printf("%s_count%s %d\n", v.name, formatLabels(v.labels, s.labelValues, "", ""), s.count)
+	}
+}
+
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, escapeLabel(values[i])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extraName, extraValue))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+// countingWriter remembers the first error so that writing can continue unchecked
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) printf(format string, a ...interface{}) {
+	if cw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(cw.w, format, a...)
+	cw.n += int64(n)
+	cw.err = err
+}
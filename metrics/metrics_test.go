@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func exposition(t *testing.T, r *Registry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	n, err := r.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("writing metrics: %s", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("reported writing %d bytes, wrote %d", n, buf.Len())
+	}
+	return buf.String()
+}
+
+func TestExposition(t *testing.T) {
+	r := NewRegistry()
+	predictions := r.NewCounter("predictions_total", "Number of predictions.", "model", "label")
+	epoch := r.NewGauge("epoch", "Last epoch.\nWith a second line.")
+	loss := r.NewGauge("loss", `Loss per "dataset".`, "dataset")
+	predictions.Inc("digits", "7")
+	predictions.Add(2, "digits", "1")
+	predictions.Inc("digits", "7")
+	predictions.Inc(`we"ird\`, "a\nb")
+	epoch.Set(3)
+	loss.Set(math.Inf(1), "fishing")
+	loss.Set(0.25, "digits")
+
+	expected := `# HELP predictions_total Number of predictions.
+# TYPE predictions_total counter
+predictions_total{model="digits",label="1"} 2
+predictions_total{model="digits",label="7"} 2
+predictions_total{model="we\"ird\\",label="a\nb"} 1
+# HELP epoch Last epoch.\nWith a second line.
+# TYPE epoch gauge
+epoch 3
+# HELP loss Loss per "dataset".
+# TYPE loss gauge
+loss{dataset="digits"} 0.25
+loss{dataset="fishing"} +Inf
+`
+	if got := exposition(t, r); got != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, expected)
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	r := NewRegistry()
+	// buckets are sorted whatever order they're given in
+	latency := r.NewHistogram("latency_seconds", "Latency.", []float64{1, 0.1, 0.5}, "model")
+	for _, v := range []float64{0.05, 0.1, 0.3, 0.5, 0.7, 2} {
+		latency.Observe(v, "digits")
+	}
+
+	// an observation equal to a bound is counted in that bucket, and every bucket includes the ones below it
+	expected := `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{model="digits",le="0.1"} 2
+latency_seconds_bucket{model="digits",le="0.5"} 4
+latency_seconds_bucket{model="digits",le="1"} 5
+latency_seconds_bucket{model="digits",le="+Inf"} 6
+latency_seconds_sum{model="digits"} 3.65
+latency_seconds_count{model="digits"} 6
+`
+	if got := exposition(t, r); got != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, expected)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("requests_total", "Requests.").Inc()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("got content type %s", contentType)
+	}
+	if !strings.Contains(w.Body.String(), "requests_total 1\n") {
+		t.Errorf("got body:\n%s", w.Body.String())
+	}
+}
+
+func TestCounterCannotDecrease(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic adding a negative delta")
+		}
+	}()
+	NewRegistry().NewCounter("total", "Total.").Add(-1)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/PaluMacil/gophernet/m"
+	"io"
+	"os"
+	"strings"
+)
+
+func predictCommand(networkName string, args []string) {
+	predictFlags := flag.NewFlagSet("predict", flag.ContinueOnError)
+	flagQuery := predictFlags.String("query", "0,1,0,0", "query is the comma separated input values, with the steps of a sequence separated by semicolons, or raw column=value pairs for a model trained with a transform")
+	flagTop := predictFlags.Int("top", 1, "top is the number of ranked labels to show (0 shows every label), besides every label a multilabel model predicts")
+	flagJSON := predictFlags.Bool("json", false, "json writes the prediction and its scores as JSON")
+	flagInput := predictFlags.String("input", "", "input is a file (or - for stdin) with one query per line to predict in a batch")
+	flagInputFormat := predictFlags.String("input-format", m.FormatText, "input-format is the format of the input file: text (comma or space separated rows, with the steps of a sequence separated by semicolons), idx, libsvm or sequence")
+	flagLabelsFile := predictFlags.String("labels-file", "", "labels-file is the IDX label file paired with an idx input file")
+	flagFormat := predictFlags.String("format", "csv", "format is the batch output format: csv or jsonl")
+	flagThreshold := predictFlags.String("threshold", "", "threshold overrides the output above which a label is predicted by a multilabel model, or a comma separated threshold per label")
+	err := predictFlags.Parse(args)
+	if err != nil {
+		fmt.Printf("parsing predict flags: %s\n", err.Error())
+		os.Exit(1)
+	}
+	model, err := m.LoadModel(networkName)
+	if err != nil {
+		fmt.Printf("loading network for %s: %s\n", networkName, err.Error())
+		os.Exit(1)
+	}
+	if *flagThreshold != "" {
+		model = withThresholds(model, *flagThreshold)
+	}
+
+	if *flagInput != "" {
+		predictBatch(model, *flagInput, *flagInputFormat, *flagLabelsFile, *flagFormat, *flagTop)
+		return
+	}
+
+	query, err := parseQuery(model, *flagQuery)
+	if err != nil {
+		fmt.Printf("parsing query: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if err := model.CheckInput(query); err != nil {
+		fmt.Printf("checking query: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	prediction := model.PredictScores(query)
+	prediction.Scores = prediction.Top(*flagTop)
+	if *flagJSON {
+		err = json.NewEncoder(os.Stdout).Encode(prediction)
+		if err != nil {
+			fmt.Printf("encoding prediction: %s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if model.Mode() == m.ModeRegression || model.Mode() == m.ModeAutoencoder {
+		for _, score := range prediction.Scores {
+			fmt.Printf("Prediction: %s = %g\n", score.Label, score.Output)
+		}
+		return
+	}
+	fmt.Println("Prediction:", prediction.Label)
+	if *flagTop != 1 || model.Mode() == m.ModeMultiLabel {
+		printScores(prediction)
+	}
+}
+
+func printScores(prediction m.Prediction) {
+	for i, score := range prediction.Scores {
+		if prediction.Calibrated {
+			fmt.Printf("%3d. %-10s output=%.5f probability=%.5f\n", i+1, score.Label, score.Output, score.Probability)
+		} else {
+			fmt.Printf("%3d. %-10s output=%.5f\n", i+1, score.Label, score.Output)
+		}
+	}
+}
+
+func predictBatch(model *m.Model, input, inputFormat, labelsFile, format string, top int) {
+	var w m.PredictionWriter
+	switch format {
+	case "csv":
+		w = m.NewCSVPredictionWriter(os.Stdout, model.Labels())
+	case "jsonl":
+		w = m.NewJSONLinesPredictionWriter(os.Stdout, top)
+	default:
+		fmt.Printf("invalid format %s, expected csv or jsonl\n", format)
+		os.Exit(1)
+	}
+
+	if inputFormat != m.FormatText {
+		// binary and sparse files are read as datasets, whose targets are ignored
+		err := model.PredictDataset(m.FileDataset{
+			Path: input,
+			Format: m.ReadOptions{
+				Format:     inputFormat,
+				LabelsPath: labelsFile,
+				InputNum:   model.InputNum(),
+				OutputNum:  model.OutputNum(),
+				Labels:     model.Labels(),
+			},
+		}, w)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "predicting batch: %s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	var r io.Reader = os.Stdin
+	if input != "-" {
+		file, err := os.Open(input)
+		if err != nil {
+			fmt.Printf("opening input file: %s\n", err.Error())
+			os.Exit(1)
+		}
+		defer file.Close()
+		r = file
+	}
+
+	rejected, err := model.PredictAll(r, w, func(err error) {
+		fmt.Fprintf(os.Stderr, "skipping row: %s\n", err.Error())
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "predicting batch: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if rejected > 0 {
+		fmt.Fprintf(os.Stderr, "%d malformed rows skipped\n", rejected)
+		os.Exit(1)
+	}
+}
+
+// parseQuery reads either comma separated input values or, for a model with a transform, raw column=value pairs
+func parseQuery(model *m.Model, query string) ([]float64, error) {
+	if !strings.Contains(query, "=") {
+		return m.ParseQuery(query)
+	}
+	values, err := m.ParseRawQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return model.EncodeRaw(values)
+}
+
+// withThresholds replaces the thresholds of a multi-label model
+func withThresholds(model *m.Model, threshold string) *m.Model {
+	thresholds, err := m.ParseThresholds(threshold, model.OutputNum())
+	if err != nil {
+		fmt.Printf("parsing thresholds: %s\n", err.Error())
+		os.Exit(1)
+	}
+	model, err = model.WithThresholds(thresholds)
+	if err != nil {
+		fmt.Printf("setting thresholds: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return model
+}
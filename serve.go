@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/PaluMacil/gophernet/m"
+	"github.com/PaluMacil/gophernet/metrics"
+	"github.com/PaluMacil/gophernet/server"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+func serve(args []string) {
+	serveFlags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flagAddr := serveFlags.String("addr", ":8080", "addr is the address to listen on")
+	flagModels := serveFlags.String("models", "digits", "models is a comma separated list of datasets to serve, each optionally as dataset@tag or an ensemble such as dataset:top5:vote")
+	flagMaxBody := serveFlags.Int64("max-body", server.DefaultMaxBodyBytes, "max-body is the largest request body in bytes, so that one batch can't exhaust memory")
+	flagReload := serveFlags.Duration("reload", 30*time.Second, "reload is how often to check for a better run or moved tag (0 disables reloading)")
+	err := serveFlags.Parse(args)
+	if err != nil {
+		fmt.Printf("parsing serve flags: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	s, err := server.New(server.DiskLoader, strings.Split(*flagModels, ","))
+	if err != nil {
+		fmt.Printf("starting server: %s\n", err.Error())
+		os.Exit(1)
+	}
+	s.MaxBodyBytes = *flagMaxBody
+	registry := metrics.NewRegistry()
+	s.Observer = metrics.NewPredictionMetrics(registry)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry)
+	mux.Handle("/", s)
+	if *flagReload > 0 {
+		go s.Watch(*flagReload, nil)
+	}
+
+	log.Printf("serving %s on %s", *flagModels, *flagAddr)
+	log.Fatal(http.ListenAndServe(*flagAddr, mux))
+}
+
+// serveTrainingMetrics exposes training progress on addr in the background. Training carries on without metrics if
+// the server fails.
+func serveTrainingMetrics(addr string) m.TrainingObserver {
+	registry := metrics.NewRegistry()
+	observer := metrics.NewTrainingMetrics(registry)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry)
+	go func() {
+		log.Printf("serving training metrics: %s", http.ListenAndServe(addr, mux))
+	}()
+	return observer
+}
@@ -0,0 +1,378 @@
+// Package server serves predictions from trained models over HTTP as JSON.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/PaluMacil/gophernet/m"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Loader resolves model references (name, name@tag or an ensemble such as name:top5) to training runs and loads their models
+type Loader interface {
+	Resolve(ref string) (string, error)
+	Load(ref string) (*m.Model, error)
+}
+
+type diskLoader struct{}
+
+func (diskLoader) Resolve(ref string) (string, error) {
+	return m.ResolveRun(ref)
+}
+
+func (diskLoader) Load(ref string) (*m.Model, error) {
+	return m.LoadModel(ref)
+}
+
+// DiskLoader loads models recorded in the analysis log from their weight files
+var DiskLoader Loader = diskLoader{}
+
+// PredictionObserver is notified of every prediction served, such as to export metrics. The label is one of the
+// model's labels so that it can't grow without bound: the most likely label, which for a multi-label prediction is
+// the highest scoring label, or empty for regression and autoencoder predictions, which have no label.
+type PredictionObserver interface {
+	ObservePrediction(model, label string, duration time.Duration)
+}
+
+// DefaultMaxBodyBytes is the largest request body read when a Server's MaxBodyBytes isn't set
+const DefaultMaxBodyBytes = 10 << 20
+
+// Server is an http.Handler serving predictions for a fixed set of model references. Models are swapped in place
+// when Reload finds that a reference points to a different run than the one loaded.
+type Server struct {
+	loader Loader
+	refs   []string
+	mu     sync.RWMutex
+	models map[string]*m.Model
+	// Observer, if set, is notified of each prediction with the model reference it was made by
+	Observer PredictionObserver
+	// MaxBodyBytes limits the size of a request body, so that one batch can't exhaust memory. It defaults to
+	// DefaultMaxBodyBytes.
+	MaxBodyBytes int64
+}
+
+// New loads every referenced model, failing if any of them can't be loaded
+func New(loader Loader, refs []string) (*Server, error) {
+	s := &Server{
+		loader: loader,
+		refs:   refs,
+		models: make(map[string]*m.Model, len(refs)),
+	}
+	for _, ref := range refs {
+		model, err := loader.Load(ref)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", ref, err)
+		}
+		s.models[ref] = model
+	}
+	return s, nil
+}
+
+// Reload loads any model whose reference now resolves to a different run, such as when a more accurate run was
+// added to the analysis log or a tag was moved. Models that fail to reload keep serving their current run.
+func (s *Server) Reload() error {
+	var failures []string
+	for _, ref := range s.refs {
+		run, err := s.loader.Resolve(ref)
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		current := s.model(ref)
+		if current != nil && current.Run() == run {
+			continue
+		}
+		model, err := s.loader.Load(ref)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("loading %s: %s", ref, err.Error()))
+			continue
+		}
+		s.mu.Lock()
+		s.models[ref] = model
+		s.mu.Unlock()
+		log.Printf("reloaded %s with run %s", ref, model.Run())
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("reloading models: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// Watch calls Reload every interval until stop is closed
+func (s *Server) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := s.Reload(); err != nil {
+				log.Println(err.Error())
+			}
+		}
+	}
+}
+
+func (s *Server) model(ref string) *m.Model {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.models[ref]
+}
+
+// ServeHTTP routes the following endpoints:
+//
+//	GET  /health
+//	GET  /models
+//	GET  /models/{ref}
+//	POST /models/{ref}/predict
+//	POST /models/{ref}/batch
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/health" {
+		s.handleHealth(w, r)
+		return
+	}
+	if r.URL.Path == "/models" || r.URL.Path == "/models/" {
+		s.handleModels(w, r)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, "/models/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	splits := strings.Split(strings.TrimPrefix(r.URL.Path, "/models/"), "/")
+	if len(splits) > 2 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	model := s.model(splits[0])
+	if model == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no model %s", splits[0]))
+		return
+	}
+	if len(splits) == 1 {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		writeJSON(w, http.StatusOK, metadataFor(splits[0], model))
+		return
+	}
+	switch splits[1] {
+	case "predict":
+		if allowMethod(w, r, http.MethodPost) {
+			s.handlePredict(w, r, splits[0], model)
+		}
+	case "batch":
+		if allowMethod(w, r, http.MethodPost) {
+			s.handleBatch(w, r, splits[0], model)
+		}
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+type metadata struct {
+	Ref       string   `json:"ref"`
+	Name      string   `json:"name"`
+	Run       string   `json:"run"`
+	Activator string   `json:"activator"`
+	Inputs    int      `json:"inputs"`
+	Outputs   int      `json:"outputs"`
+	Labels    []string `json:"labels"`
+	// Sequence means Inputs is the number of values in each step of a sequence of any length
+	Sequence bool `json:"sequence,omitempty"`
+	// Combination and Members are only set for an ensemble, whose Run joins the runs of its members
+	Combination string   `json:"combination,omitempty"`
+	Members     []string `json:"members,omitempty"`
+}
+
+func metadataFor(ref string, model *m.Model) metadata {
+	var members []string
+	for _, member := range model.Members() {
+		members = append(members, member.Run())
+	}
+	return metadata{
+		Ref:         ref,
+		Name:        model.Name(),
+		Run:         model.Run(),
+		Activator:   model.Activator(),
+		Inputs:      model.InputNum(),
+		Sequence:    model.Sequence(),
+		Outputs:     model.OutputNum(),
+		Labels:      model.Labels(),
+		Combination: model.Combination(),
+		Members:     members,
+	}
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	s.mu.RLock()
+	loaded := len(s.models)
+	s.mu.RUnlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "ok",
+		"models": loaded,
+	})
+}
+
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	s.mu.RLock()
+	models := make([]metadata, 0, len(s.models))
+	for ref, model := range s.models {
+		models = append(models, metadataFor(ref, model))
+	}
+	s.mu.RUnlock()
+	sort.Slice(models, func(i, j int) bool {
+		return models[i].Ref < models[j].Ref
+	})
+	writeJSON(w, http.StatusOK, models)
+}
+
+type predictRequest struct {
+	Inputs []float64 `json:"inputs"`
+	// Values are raw values keyed by column name, used instead of Inputs for models trained with a transform
+	Values map[string]string `json:"values"`
+	// Top limits the ranked scores returned, zero returns every label
+	Top int `json:"top"`
+}
+
+// predict makes a prediction and notifies the observer
+func (s *Server) predict(ref string, model *m.Model, inputs []float64) m.Prediction {
+	start := time.Now()
+	prediction := model.PredictScores(inputs)
+	if s.Observer != nil {
+		s.Observer.ObservePrediction(ref, observedLabel(prediction), time.Since(start))
+	}
+	return prediction
+}
+
+// observedLabel is the label a prediction is observed with. Scores are ranked unless the prediction has values.
+func observedLabel(prediction m.Prediction) string {
+	if prediction.Values != nil || len(prediction.Scores) == 0 {
+		return ""
+	}
+	return prediction.Scores[0].Label
+}
+
+// decode reads a JSON request body of at most MaxBodyBytes
+func (s *Server) decode(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	limit := s.MaxBodyBytes
+	if limit <= 0 {
+		limit = DefaultMaxBodyBytes
+	}
+	return json.NewDecoder(http.MaxBytesReader(w, r.Body, limit)).Decode(v)
+}
+
+func (s *Server) handlePredict(w http.ResponseWriter, r *http.Request, ref string, model *m.Model) {
+	var req predictRequest
+	if err := s.decode(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("decoding request: %s", err.Error()))
+		return
+	}
+	inputs, err := inputsFor(model, req.Inputs, req.Values)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	prediction := s.predict(ref, model, inputs)
+	prediction.Scores = prediction.Top(req.Top)
+	writeJSON(w, http.StatusOK, prediction)
+}
+
+var errBothInputs = errors.New("only one of inputs and values can be given")
+
+type batchRequest struct {
+	Inputs [][]float64         `json:"inputs"`
+	Values []map[string]string `json:"values"`
+	Top    int                 `json:"top"`
+}
+
+type batchResponse struct {
+	Predictions []m.Prediction `json:"predictions"`
+}
+
+func (s *Server) handleBatch(w http.ResponseWriter, r *http.Request, ref string, model *m.Model) {
+	var req batchRequest
+	if err := s.decode(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("decoding request: %s", err.Error()))
+		return
+	}
+	if req.Inputs != nil && req.Values != nil {
+		writeError(w, http.StatusBadRequest, errBothInputs.Error())
+		return
+	}
+	// check every row before predicting any of them
+	rows := req.Inputs
+	if len(req.Values) > 0 {
+		rows = make([][]float64, len(req.Values))
+	}
+	for i := range rows {
+		var values map[string]string
+		if len(req.Values) > 0 {
+			values = req.Values[i]
+		}
+		inputs, err := inputsFor(model, rows[i], values)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("row %d: %s", i, err.Error()))
+			return
+		}
+		rows[i] = inputs
+	}
+	resp := batchResponse{
+		Predictions: make([]m.Prediction, len(rows)),
+	}
+	for i, inputs := range rows {
+		prediction := s.predict(ref, model, inputs)
+		prediction.Scores = prediction.Top(req.Top)
+		resp.Predictions[i] = prediction
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// inputsFor encodes raw values when they're given instead of inputs and checks the size of the inputs
+func inputsFor(model *m.Model, inputs []float64, values map[string]string) ([]float64, error) {
+	if inputs != nil && values != nil {
+		return nil, errBothInputs
+	}
+	if values != nil {
+		var err error
+		inputs, err = model.EncodeRaw(values)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return inputs, model.CheckInput(inputs)
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("writing response: %s", err.Error())
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,286 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/PaluMacil/gophernet/m"
+	"github.com/PaluMacil/gophernet/metrics"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeLoader serves untrained models named after the run each reference resolves to
+type fakeLoader struct {
+	mu   sync.Mutex
+	runs map[string]string
+}
+
+func (l *fakeLoader) Resolve(ref string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	run, ok := l.runs[ref]
+	if !ok {
+		return "", fmt.Errorf("no runs of %s", ref)
+	}
+	return run, nil
+}
+
+func (l *fakeLoader) Load(ref string) (*m.Model, error) {
+	run, err := l.Resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	net := m.NewNetwork(m.Config{
+		Name:         run,
+		InputNum:     4,
+		HiddenNum:    3,
+		OutputNum:    2,
+		LayerNum:     3,
+		Activator:    m.Sigmoid{},
+		TargetLabels: []string{"yes", "no"},
+		Seed:         1,
+	})
+	return net.Model()
+}
+
+func (l *fakeLoader) set(ref, run string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.runs[ref] = run
+}
+
+func newTestServer(t *testing.T) (*Server, *fakeLoader, *httptest.Server) {
+	t.Helper()
+	loader := &fakeLoader{runs: map[string]string{"fishing": "first", "digits": "digits"}}
+	s, err := New(loader, []string{"fishing", "digits"})
+	if err != nil {
+		t.Fatalf("creating server: %s", err)
+	}
+	registry := metrics.NewRegistry()
+	s.Observer = metrics.NewPredictionMetrics(registry)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry)
+	mux.Handle("/", s)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return s, loader, ts
+}
+
+// do sends a request with an optional JSON body and decodes the JSON response into v, returning the status
+func do(t *testing.T, method, url, body string, v interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("creating request: %s", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("sending request: %s", err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("decoding response: %s", err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestHealth(t *testing.T) {
+	_, _, ts := newTestServer(t)
+	var health struct {
+		Status string `json:"status"`
+		Models int    `json:"models"`
+	}
+	if status := do(t, http.MethodGet, ts.URL+"/health", "", &health); status != http.StatusOK {
+		t.Fatalf("got status %d", status)
+	}
+	if health.Status != "ok" || health.Models != 2 {
+		t.Errorf("got %+v, expected ok with 2 models", health)
+	}
+	if status := do(t, http.MethodPost, ts.URL+"/health", "", nil); status != http.StatusMethodNotAllowed {
+		t.Errorf("POST got status %d, expected %d", status, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestModels(t *testing.T) {
+	_, _, ts := newTestServer(t)
+	var models []metadata
+	if status := do(t, http.MethodGet, ts.URL+"/models", "", &models); status != http.StatusOK {
+		t.Fatalf("got status %d", status)
+	}
+	if len(models) != 2 || models[0].Ref != "digits" || models[1].Ref != "fishing" {
+		t.Fatalf("got %+v, expected digits and fishing in order", models)
+	}
+	if models[1].Name != "first" || models[1].Inputs != 4 || models[1].Outputs != 2 {
+		t.Errorf("got %+v, expected first with 4 inputs and 2 outputs", models[1])
+	}
+	if status := do(t, http.MethodGet, ts.URL+"/models/missing", "", nil); status != http.StatusNotFound {
+		t.Errorf("missing model got status %d, expected %d", status, http.StatusNotFound)
+	}
+}
+
+func TestPredict(t *testing.T) {
+	_, _, ts := newTestServer(t)
+	var prediction m.Prediction
+	status := do(t, http.MethodPost, ts.URL+"/models/fishing/predict", `{"inputs": [0, 1, 0, 0], "top": 1}`, &prediction)
+	if status != http.StatusOK {
+		t.Fatalf("got status %d", status)
+	}
+	if prediction.Label != "yes" && prediction.Label != "no" {
+		t.Errorf("got label %q, expected yes or no", prediction.Label)
+	}
+	if len(prediction.Scores) != 1 || prediction.Scores[0].Label != prediction.Label {
+		t.Errorf("got scores %+v, expected only the predicted label", prediction.Scores)
+	}
+	if status := do(t, http.MethodGet, ts.URL+"/models/fishing/predict", "", nil); status != http.StatusMethodNotAllowed {
+		t.Errorf("GET got status %d, expected %d", status, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	_, _, ts := newTestServer(t)
+	var resp batchResponse
+	status := do(t, http.MethodPost, ts.URL+"/models/fishing/batch", `{"inputs": [[0, 1, 0, 0], [1, 1, 1, 1], [0, 0, 0, 0]]}`, &resp)
+	if status != http.StatusOK {
+		t.Fatalf("got status %d", status)
+	}
+	if len(resp.Predictions) != 3 {
+		t.Fatalf("got %d predictions, expected 3", len(resp.Predictions))
+	}
+	for i, prediction := range resp.Predictions {
+		if len(prediction.Scores) != 2 {
+			t.Errorf("prediction %d has %d scores, expected every label", i, len(prediction.Scores))
+		}
+	}
+}
+
+func TestBadRequests(t *testing.T) {
+	s, _, ts := newTestServer(t)
+	s.MaxBodyBytes = 64
+	tests := []struct {
+		name string
+		path string
+		body string
+	}{
+		{"too few inputs", "/models/fishing/predict", `{"inputs": [0, 1]}`},
+		{"too many inputs", "/models/fishing/predict", `{"inputs": [0, 1, 0, 0, 1]}`},
+		{"batch row", "/models/fishing/batch", `{"inputs": [[0, 1, 0, 0], [1, 1]]}`},
+		{"malformed", "/models/fishing/predict", `{"inputs": [0, 1`},
+		{"no transform", "/models/fishing/predict", `{"values": {"Wind": "Strong"}}`},
+		{"too large", "/models/fishing/batch", `{"inputs": [` + strings.Repeat("[0, 1, 0, 0], ", 10) + `[0, 1, 0, 0]]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp struct {
+				Error string `json:"error"`
+			}
+			if status := do(t, http.MethodPost, ts.URL+tt.path, tt.body, &resp); status != http.StatusBadRequest {
+				t.Errorf("got status %d, expected %d", status, http.StatusBadRequest)
+			}
+			if resp.Error == "" {
+				t.Error("expected an error message")
+			}
+		})
+	}
+}
+
+func TestInputsAndValues(t *testing.T) {
+	_, _, ts := newTestServer(t)
+	for path, body := range map[string]string{
+		"/models/fishing/predict": `{"inputs": [0, 1, 0, 0], "values": {"Wind": "Strong"}}`,
+		"/models/fishing/batch":   `{"inputs": [[0, 1, 0, 0]], "values": [{"Wind": "Strong"}]}`,
+	} {
+		var resp struct {
+			Error string `json:"error"`
+		}
+		if status := do(t, http.MethodPost, ts.URL+path, body, &resp); status != http.StatusBadRequest {
+			t.Errorf("%s got status %d, expected %d", path, status, http.StatusBadRequest)
+		}
+		if resp.Error != errBothInputs.Error() {
+			t.Errorf("%s got error %q, expected %q", path, resp.Error, errBothInputs.Error())
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	_, _, ts := newTestServer(t)
+	for i := 0; i < 3; i++ {
+		do(t, http.MethodPost, ts.URL+"/models/fishing/predict", `{"inputs": [0, 1, 0, 0]}`, nil)
+	}
+	resp, err := http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("getting metrics: %s", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading metrics: %s", err)
+	}
+	var predictions float64
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.HasPrefix(line, `gophernet_predictions_total{model="fishing",`) {
+			var value float64
+			fmt.Sscanf(line[strings.LastIndex(line, " ")+1:], "%g", &value)
+			predictions += value
+		}
+	}
+	if predictions != 3 {
+		t.Errorf("counted %g predictions, expected 3 in:\n%s", predictions, body)
+	}
+	if !bytes.Contains(body, []byte(`gophernet_prediction_duration_seconds_count{model="fishing"} 3`)) {
+		t.Errorf("expected 3 observed latencies in:\n%s", body)
+	}
+}
+
+func TestObservedLabel(t *testing.T) {
+	scores := []m.Score{{Label: "b", Output: 0.9}, {Label: "a", Output: 0.6}, {Label: "c", Output: 0.1}}
+	tests := []struct {
+		name       string
+		prediction m.Prediction
+		expected   string
+	}{
+		{"classification", m.Prediction{Label: "b", Scores: scores}, "b"},
+		{"multi-label", m.Prediction{Label: "b,a", Scores: scores, Labels: []string{"b", "a"}}, "b"},
+		{"multi-label without labels", m.Prediction{Scores: scores, Labels: []string{}}, "b"},
+		{"regression", m.Prediction{Scores: scores, Values: []float64{0.9, 0.6, 0.1}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if label := observedLabel(tt.prediction); label != tt.expected {
+				t.Errorf("got %q, expected %q", label, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	s, loader, ts := newTestServer(t)
+	loader.set("fishing", "second")
+	if err := s.Reload(); err != nil {
+		t.Fatalf("reloading: %s", err)
+	}
+	var model metadata
+	if status := do(t, http.MethodGet, ts.URL+"/models/fishing", "", &model); status != http.StatusOK {
+		t.Fatalf("got status %d", status)
+	}
+	if model.Name != "second" {
+		t.Errorf("serving %s, expected the reloaded model second", model.Name)
+	}
+
+	// a reference that no longer resolves keeps serving its current model
+	loader.mu.Lock()
+	delete(loader.runs, "fishing")
+	loader.mu.Unlock()
+	if err := s.Reload(); err == nil {
+		t.Error("expected an error reloading a reference with no runs")
+	}
+	if got := s.model("fishing"); got == nil || got.Name() != "second" {
+		t.Error("expected the current model to keep serving after a failed reload")
+	}
+}
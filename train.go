@@ -0,0 +1,383 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/PaluMacil/gophernet/m"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func trainCommand(networkName string, args []string) {
+	// parse training flags
+	trainFlags := flag.NewFlagSet("train", flag.ContinueOnError)
+	flagNumInputs := trainFlags.Int("input", 64, "input controls the number of input nodes")
+	flagNumHidden := trainFlags.Int("hidden", 30, "output controls the number of hidden nodes")
+	flagNumOutput := trainFlags.Int("output", 10, "output controls the number of output nodes (in autoencoder mode, it's the number of target columns after the inputs, which are ignored)")
+	flagNumLayers := trainFlags.Int("layers", 3, "layers controls the total number of layers to use (3 means one hidden)")
+	flagNumEpochs := trainFlags.Int("epochs", 6, "number of epochs")
+	flagActivator := trainFlags.String("activator", "sigmoid", "activator is the activation function to use (default is sigmoid)")
+	flagLearningRate := trainFlags.Float64("rate", .05, "rate is the learning rate")
+	flagTargetLabels := trainFlags.String("labels", "0,1,2,3,4,5,6,7,8,9", "labels are name to call each output")
+	flagMode := trainFlags.String("mode", m.ModeClassification, "mode is classification, regression for linear outputs predicting continuous targets (default for a numeric transform target), multilabel for any number of true labels per line or autoencoder to reconstruct the inputs")
+	flagBottleneck := trainFlags.Int("bottleneck", 0, "bottleneck is the number of nodes of the middle layer in autoencoder mode (default is -hidden)")
+	flagLoss := trainFlags.String("loss", "", "loss is the loss function to minimize: mse, huber, bce or ce (categorical cross-entropy with softmax outputs for classifiers) (default is bce in multilabel mode and mse otherwise)")
+	flagThreshold := trainFlags.String("threshold", "0.5", "threshold is the output above which a label is predicted in multilabel mode, or a comma separated threshold per label")
+	flagHuberDelta := trainFlags.Float64("huber-delta", 1, "huber-delta is the error at which the huber loss turns from quadratic to linear")
+	flagInit := trainFlags.String("init", "", "init is the weight initializer: "+strings.Join(m.Initializers, ", ")+" (default is xavier-uniform for sigmoid and lecun for tanh)")
+	flagBias := trainFlags.Bool("bias", false, "bias adds a bias starting at zero to every node after the input layer")
+	flagL1 := trainFlags.Float64("l1", 0, "l1 is the coefficient of the L1 weight penalty")
+	flagL2 := trainFlags.Float64("l2", 0, "l2 is the coefficient of the L2 weight penalty (weight decay)")
+	flagMaxNorm := trainFlags.String("max-norm", "", "max-norm limits the norm of each node's incoming weights, or is a comma separated limit per layer of weights where 0 is unlimited")
+	flagDropout := trainFlags.String("dropout", "", "dropout is the fraction of nodes dropped while training, one rate for every hidden layer or a comma separated rate for the input and each hidden layer")
+	flagNorm := trainFlags.String("norm", "", "norm normalizes the weighted sums of the hidden layers: none, batch or layer, or a comma separated normalization per hidden layer")
+	flagClipValue := trainFlags.Float64("clip-value", 0, "clip-value limits every value of each gradient to plus or minus this much, before it is scaled by the learning rate (0 disables)")
+	flagClipNorm := trainFlags.Float64("clip-norm", 0, "clip-norm scales each line's gradients down together when their combined norm is over this, before they are scaled by the learning rate (0 disables)")
+	flagOnNaN := trainFlags.String("on-nan", m.OnNaNAbort, "on-nan is what training does when weights stop being finite numbers: abort, or rollback to the last good epoch")
+	flagData := trainFlags.String("data", "", "data is the training file (default is <dataset>.data)")
+	flagTest := trainFlags.String("test", "", "test is the test file used for accuracy (default is data/test/<dataset>.data)")
+	data := addDataFlags(trainFlags)
+	flagStream := trainFlags.Bool("stream", false, "stream reads the data file again each epoch instead of loading it into memory")
+	flagShuffle := trainFlags.Int("shuffle", 0, "shuffle is the number of lines to buffer when shuffling each epoch (0 disables shuffling)")
+	flagSeed := trainFlags.Int64("seed", 0, "seed for weight initialization, shuffling, augmentation and dropout (default is the current time)")
+	flagImage := trainFlags.String("image", "", "image is the WIDTHxHEIGHT of image inputs for augmentation and convolution (default is a square of the inputs)")
+	flagCNN := trainFlags.String("cnn", "", "cnn lists convolution and pooling layers ahead of the hidden layers, such as conv:8x3/p1,maxpool:2")
+	flagRNN := trainFlags.String("rnn", "", "rnn lists recurrent layers (rnn, gru or lstm) ahead of the hidden layers, such as lstm:32, which read sequence data with input values per step")
+	flagShift := trainFlags.Int("augment-shift", 0, "augment-shift randomly shifts images up to this many pixels each way every epoch")
+	flagRotate := trainFlags.Float64("augment-rotate", 0, "augment-rotate randomly rotates images up to this many degrees each way every epoch")
+	flagNoise := trainFlags.Float64("augment-noise", 0, "augment-noise is the standard deviation of random noise added to each intensity every epoch")
+	flagElastic := trainFlags.Float64("augment-elastic", 0, "augment-elastic is the most pixels an elastic distortion moves part of an image every epoch")
+	flagSigma := trainFlags.Float64("augment-sigma", 1, "augment-sigma is the smoothness of the elastic distortion")
+	flagMetrics := trainFlags.String("metrics", "", "metrics is an address to expose training metrics on at /metrics while training (e.g. :9090)")
+	err := trainFlags.Parse(args)
+	if err != nil {
+		fmt.Printf("parsing train flags: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	// seed the run with pseudo random values unless a seed was given, printing the seed so that the run can be repeated
+	seed := *flagSeed
+	if seed == 0 {
+		seed = time.Now().UTC().UnixNano()
+		fmt.Printf("Seed %d\n", seed)
+	}
+
+	if *flagNumLayers < 3 {
+		fmt.Println("cannot have fewer than three layers")
+		os.Exit(1)
+	}
+
+	activator, ok := m.ActivatorLookup[*flagActivator]
+	if !ok {
+		fmt.Println("invalid activator")
+		os.Exit(1)
+	}
+
+	if *flagInit != "" {
+		if err := m.CheckInitializer(*flagInit); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if *flagL1 < 0 || *flagL2 < 0 {
+		fmt.Println("weight penalties cannot be negative")
+		os.Exit(1)
+	}
+	maxNorm, err := m.ParseMaxNorm(*flagMaxNorm, *flagNumLayers-1)
+	if err != nil {
+		fmt.Printf("parsing max-norm: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	dropout, err := m.ParseDropout(*flagDropout, *flagNumLayers)
+	if err != nil {
+		fmt.Printf("parsing dropout: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	norm, err := m.ParseNorm(*flagNorm, *flagNumLayers)
+	if err != nil {
+		fmt.Printf("parsing norm: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	if *flagClipValue < 0 || *flagClipNorm < 0 {
+		fmt.Println("clip-value and clip-norm cannot be negative")
+		os.Exit(1)
+	}
+	if err := m.CheckOnNaN(*flagOnNaN); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	format := data.options()
+	if len(format.InputColumns) > 0 {
+		*flagNumInputs = len(format.InputColumns)
+	}
+	if len(format.TargetColumns) > 0 {
+		*flagNumOutput = len(format.TargetColumns)
+	}
+
+	filename := *flagData
+	if filename == "" {
+		filename = networkName + ".data"
+	}
+	// a data file prepared from a schema has its fitted transform alongside it, which knows the sizes and labels
+	transform, err := transformFor(filename, trainFlags)
+	if err != nil {
+		fmt.Printf("loading transform: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if transform != nil {
+		*flagNumInputs = transform.InputNum()
+		*flagNumOutput = transform.OutputNum()
+		if !isFlagSet(trainFlags, "labels") {
+			*flagTargetLabels = strings.Join(transform.Labels(), ",")
+		}
+		if !isFlagSet(trainFlags, "mode") && transform.IsRegression() {
+			*flagMode = m.ModeRegression
+		}
+	}
+	// an autoencoder reconstructs its inputs, so it has an output for each input and any target columns are skipped
+	if *flagMode == m.ModeAutoencoder {
+		format.OutputNum = 0
+		if isFlagSet(trainFlags, "output") || len(format.TargetColumns) > 0 || transform != nil {
+			format.OutputNum = *flagNumOutput
+		}
+		*flagNumOutput = *flagNumInputs
+		if !isFlagSet(trainFlags, "labels") {
+			*flagTargetLabels = strings.Join(m.InputLabels(*flagNumInputs, format.InputColumns), ",")
+		}
+		if *flagBottleneck == 0 {
+			*flagBottleneck = *flagNumHidden
+		}
+		if *flagBottleneck < 1 {
+			fmt.Println("bottleneck must be positive")
+			os.Exit(1)
+		}
+		if *flagRNN != "" {
+			fmt.Println("an autoencoder can't have recurrent layers, since sequences can't be reconstructed")
+			os.Exit(1)
+		}
+	}
+	if err := m.CheckMode(*flagMode, *flagLoss, activator); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	thresholds, err := m.ParseThresholds(*flagThreshold, *flagNumOutput)
+	if err != nil {
+		fmt.Printf("parsing thresholds: %s\n", err.Error())
+		os.Exit(1)
+	}
+	// regression outputs are named y, or y1, y2 and so on, unless labels are given
+	if *flagMode == m.ModeRegression && !isFlagSet(trainFlags, "labels") && transform == nil {
+		names := []string{"y"}
+		if *flagNumOutput > 1 {
+			names = make([]string, *flagNumOutput)
+			for i := range names {
+				names[i] = "y" + strconv.Itoa(i+1)
+			}
+		}
+		*flagTargetLabels = strings.Join(names, ",")
+	}
+
+	labelSplits := strings.Split(*flagTargetLabels, ",")
+	if len(labelSplits) != *flagNumOutput {
+		fmt.Printf("expected %d target labels, got %d\n", *flagNumOutput, len(labelSplits))
+		os.Exit(1)
+	}
+
+	config := m.Config{
+		Name:         networkName,
+		InputNum:     *flagNumInputs,
+		HiddenNum:    *flagNumHidden,
+		OutputNum:    *flagNumOutput,
+		LayerNum:     *flagNumLayers,
+		Epochs:       *flagNumEpochs,
+		TargetLabels: labelSplits,
+		Activator:    activator,
+		LearningRate: *flagLearningRate,
+		Format:       format,
+		TestPath:     *flagTest,
+		Transform:    transform,
+		Seed:         seed,
+		Mode:         *flagMode,
+		Loss:         *flagLoss,
+		HuberDelta:   *flagHuberDelta,
+		Thresholds:   thresholds,
+		Init:         *flagInit,
+		Bias:         *flagBias,
+		L1:           *flagL1,
+		L2:           *flagL2,
+		MaxNorm:      maxNorm,
+		Dropout:      dropout,
+		Norm:         norm,
+		ClipValue:    *flagClipValue,
+		ClipNorm:     *flagClipNorm,
+		Bottleneck:   *flagBottleneck,
+		OnNaN:        *flagOnNaN,
+	}
+
+	if *flagMetrics != "" {
+		config.Observer = serveTrainingMetrics(*flagMetrics)
+	}
+
+	if *flagCNN != "" {
+		config.CNN, err = m.ParseCNN(*flagCNN)
+		if err != nil {
+			fmt.Printf("parsing cnn: %s\n", err.Error())
+			os.Exit(1)
+		}
+		config.ImageWidth, config.ImageHeight, err = imageSize(*flagImage, config.InputNum)
+		if err != nil {
+			fmt.Printf("parsing image size: %s\n", err.Error())
+			os.Exit(1)
+		}
+		err = m.CheckCNN(config.CNN, config.InputNum, config.ImageWidth, config.ImageHeight)
+		if err != nil {
+			fmt.Printf("invalid cnn: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if *flagRNN != "" {
+		if len(config.CNN) > 0 {
+			fmt.Println("recurrent and convolutional layers cannot be combined")
+			os.Exit(1)
+		}
+		config.RNN, err = m.ParseRNN(*flagRNN)
+		if err != nil {
+			fmt.Printf("parsing rnn: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+	if len(config.RNN) > 0 && format.Format != m.FormatSequence {
+		fmt.Printf("recurrent layers read sequences, so -format=%s must be given\n", m.FormatSequence)
+		os.Exit(1)
+	}
+	if len(config.RNN) == 0 && format.Format == m.FormatSequence {
+		fmt.Println("sequence data needs recurrent layers, given with -rnn")
+		os.Exit(1)
+	}
+
+	var augmentation *m.Augmentation
+	if *flagShift > 0 || *flagRotate > 0 || *flagNoise > 0 || *flagElastic > 0 {
+		width, height, err := imageSize(*flagImage, config.InputNum)
+		if err != nil {
+			fmt.Printf("parsing image size: %s\n", err.Error())
+			os.Exit(1)
+		}
+		augmentation = &m.Augmentation{
+			Width:   width,
+			Height:  height,
+			Shift:   *flagShift,
+			Rotate:  *flagRotate,
+			Noise:   *flagNoise,
+			Elastic: *flagElastic,
+			Sigma:   *flagSigma,
+			Low:     0,
+			High:    1,
+		}
+		err = augmentation.Check(config.InputNum)
+		if err != nil {
+			fmt.Printf("invalid augmentation: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	train(config, filename, *flagStream, *flagShuffle, augmentation)
+}
+
+// imageSize parses a size such as 8x8, or finds the square that the inputs fill when size is empty
+func imageSize(size string, inputNum int) (int, int, error) {
+	if size == "" {
+		side := int(math.Sqrt(float64(inputNum)) + 0.5)
+		if side*side != inputNum {
+			return 0, 0, fmt.Errorf("%d inputs aren't a square image, so -image must be given", inputNum)
+		}
+		return side, side, nil
+	}
+	splits := strings.Split(size, "x")
+	if len(splits) != 2 {
+		return 0, 0, fmt.Errorf("expected WIDTHxHEIGHT, got %s", size)
+	}
+	width, err := strconv.Atoi(splits[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("width: %w", err)
+	}
+	height, err := strconv.Atoi(splits[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("height: %w", err)
+	}
+	return width, height, nil
+}
+
+func train(config m.Config, filename string, stream bool, shuffle int, augmentation *m.Augmentation) {
+	var dataset m.Dataset = m.FileDataset{
+		Path:   filename,
+		Format: config.DataFormat(),
+	}
+	if !stream {
+		lines, err := m.ReadAll(dataset)
+		if err != nil {
+			fmt.Printf("couldn't get lines from file: %s\n", err.Error())
+			os.Exit(1)
+		}
+		dataset = lines
+	}
+	if shuffle > 0 {
+		dataset = m.Shuffled(dataset, shuffle, config.Rand(m.RandShuffle))
+	}
+	// an autoencoder's targets are taken before augmentation, so that it learns to reconstruct the undistorted inputs
+	if config.Mode == m.ModeAutoencoder {
+		dataset = m.Reconstructing(dataset)
+	}
+	if augmentation != nil {
+		dataset = m.Augmented(dataset, *augmentation, config.Rand(m.RandAugment))
+	}
+
+	network := m.NewNetwork(config)
+	err := network.Train(dataset)
+	if err != nil {
+		fmt.Printf("training network: %s\n", err.Error())
+		os.Exit(1)
+	}
+	err = network.Analyze()
+	if err != nil {
+		fmt.Printf("doing analysis of network: %s\n", err.Error())
+		os.Exit(1)
+	}
+	fmt.Println("Training complete")
+}
+
+// transformFor reads the fitted schema for a data file if there is one. Sizes given as flags must match it.
+func transformFor(dataFilename string, trainFlags *flag.FlagSet) (*m.Schema, error) {
+	transformFilename := m.TransformPath(dataFilename)
+	if _, err := os.Stat(transformFilename); os.IsNotExist(err) {
+		return nil, nil
+	}
+	transform, err := m.ReadSchema(transformFilename)
+	if err != nil {
+		return nil, err
+	}
+	var mismatch error
+	trainFlags.Visit(func(f *flag.Flag) {
+		var expected string
+		switch f.Name {
+		case "input":
+			expected = strconv.Itoa(transform.InputNum())
+		case "output":
+			expected = strconv.Itoa(transform.OutputNum())
+		default:
+			return
+		}
+		if f.Value.String() != expected {
+			mismatch = fmt.Errorf("-%s is %s but %s expects %s", f.Name, f.Value.String(), transformFilename, expected)
+		}
+	})
+	return &transform, mismatch
+}